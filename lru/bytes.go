@@ -0,0 +1,115 @@
+package lru
+
+import (
+	"container/list"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// BytesLRU 是键为 []byte 的 LRU 缓存。
+// 切片不可比较，不能直接作为 map 的键，因此内部以 string 保存键；
+// 查找时使用 m[string(b)] 的形式，编译器不会为这种转换分配内存。
+//
+// 键的语义：
+// Add 会复制一份键，调用方之后修改传入的切片不会影响缓存。
+//
+
+type BytesLRU struct {
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(k string, v cm.Value)
+
+	ll    *list.List
+	cache map[string]*list.Element
+}
+
+type bytesEntry struct {
+	k string
+	v cm.Value
+}
+
+// NewBytesLRU creates a new Cache.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
+func NewBytesLRU(maxEntries int) *BytesLRU {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
+	return &BytesLRU{
+		MaxEntries: maxEntries,
+		ll:         list.New(),
+		cache:      make(map[string]*list.Element),
+	}
+}
+
+// Add adds a value to the cache. The key is copied.
+func (lru *BytesLRU) Add(k []byte, v cm.Value) {
+	strictCheck("BytesLRU", lru.cache != nil, lru.MaxEntries >= 0)
+
+	if lru.cache == nil {
+		lru.cache = make(map[string]*list.Element)
+		lru.ll = list.New()
+	}
+
+	if ee, ok := lru.cache[string(k)]; ok {
+		lru.ll.MoveToFront(ee)
+		ee.Value.(*bytesEntry).v = v
+		return
+	}
+	if (lru.MaxEntries > 0) && (lru.ll.Len() == lru.MaxEntries) {
+		b := lru.ll.Back()
+		lru.ll.Remove(b)
+		delete(lru.cache, b.Value.(*bytesEntry).k)
+	}
+	kv := &bytesEntry{k: string(k), v: v}
+	lru.cache[kv.k] = lru.ll.PushFront(kv)
+}
+
+// Get looks up a key's value from the cache without allocating.
+func (lru *BytesLRU) Get(k []byte) (v cm.Value, ok bool) {
+	strictCheck("BytesLRU", lru.cache != nil, lru.MaxEntries >= 0)
+
+	if lru.cache == nil {
+		return nil, false
+	}
+
+	if ee, hit := lru.cache[string(k)]; hit {
+		lru.ll.MoveToFront(ee)
+		return ee.Value.(*bytesEntry).v, true
+	}
+	return nil, false
+}
+
+// Remove removes the provided key from the cache.
+func (lru *BytesLRU) Remove(k []byte) {
+	if lru.cache == nil {
+		return
+	}
+
+	if ee, hit := lru.cache[string(k)]; hit {
+		lru.ll.Remove(ee)
+		delete(lru.cache, ee.Value.(*bytesEntry).k)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (lru *BytesLRU) Len() int {
+	if lru.cache == nil {
+		return 0
+	}
+
+	return lru.ll.Len()
+}
+
+// Clear purges all entries from the cache.
+func (lru *BytesLRU) Clear() {
+	if lru.OnEvicted != nil {
+		for _, e := range lru.cache {
+			kv := e.Value.(*bytesEntry)
+			lru.OnEvicted(kv.k, kv.v)
+		}
+	}
+	lru.ll = nil
+	lru.cache = nil
+}
@@ -0,0 +1,83 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestBytesLRUGet(t *testing.T) {
+	getTests := []struct {
+		name       string
+		keyToAdd   []byte
+		keyToGet   []byte
+		expectedOk bool
+	}{
+		{"bytes_hit", []byte("myKey"), []byte("myKey"), true},
+		{"bytes_miss", []byte("myKey"), []byte("nonsense"), false},
+		{"empty_hit", []byte{}, nil, true},
+	}
+	for _, tt := range getTests {
+		lru := lru.NewBytesLRU(0)
+		lru.Add(tt.keyToAdd, 1234)
+		val, ok := lru.Get(tt.keyToGet)
+		if ok != tt.expectedOk {
+			t.Fatalf("%s: cache hit = %v; want %v", tt.name, ok, !ok)
+		} else if ok && val != 1234 {
+			t.Fatalf("%s expected get to return 1234 but got %v", tt.name, val)
+		}
+	}
+}
+
+func TestBytesLRUKeyCopy(t *testing.T) {
+	lru := lru.NewBytesLRU(0)
+	k := []byte("myKey")
+	lru.Add(k, 1234)
+	k[0] = 'M'
+
+	if _, ok := lru.Get(k); ok {
+		t.Fatal("TestBytesLRUKeyCopy found an entry under the mutated key")
+	}
+	if _, ok := lru.Get([]byte("myKey")); !ok {
+		t.Fatal("TestBytesLRUKeyCopy lost the entry after the key was mutated")
+	}
+}
+
+func TestBytesLRURemove(t *testing.T) {
+	lru := lru.NewBytesLRU(1)
+	lru.Add([]byte("myKey"), 1234)
+	lru.Add([]byte("other"), 5678)
+	if _, ok := lru.Get([]byte("myKey")); ok {
+		t.Fatal("TestBytesLRURemove kept an entry over capacity")
+	}
+
+	lru.Remove([]byte("other"))
+	if _, ok := lru.Get([]byte("other")); ok {
+		t.Fatal("TestBytesLRURemove returned a removed entry")
+	}
+	if n := lru.Len(); n != 0 {
+		t.Fatalf("TestBytesLRURemove failed.  Expected len %d, got %d", 0, n)
+	}
+}
+
+func TestBytesLRUGetAllocs(t *testing.T) {
+	lru := lru.NewBytesLRU(0)
+	k := []byte("myKey")
+	lru.Add(k, 1234)
+
+	if n := testing.AllocsPerRun(100, func() { lru.Get(k) }); n != 0 {
+		t.Fatalf("TestBytesLRUGetAllocs failed.  Expected 0 allocs, got %v", n)
+	}
+}
+
+func BenchmarkBytesLRUGet(b *testing.B) {
+	lru := lru.NewBytesLRU(0)
+	k := []byte("myKey")
+	lru.Add(k, 1234)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lru.Get(k)
+	}
+}
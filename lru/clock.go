@@ -0,0 +1,7 @@
+package lru
+
+import "time"
+
+// now is the clock used by the time-aware features of the caches.
+// Tests replace it to control time.
+var now = time.Now
@@ -0,0 +1,101 @@
+package lru
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// Codec encodes and decodes the entries of a cache as a whole, in order
+// from the most to the least recently used.
+type Codec interface {
+	Encode(w io.Writer, entries []cm.Entry) error
+	Decode(r io.Reader) ([]cm.Entry, error)
+}
+
+// GobCodec encodes entries with encoding/gob. As with the default stream,
+// concrete key and value types other than the gob builtins must be
+// registered with gob.Register first.
+type GobCodec struct{}
+
+// Encode writes entries to w.
+func (GobCodec) Encode(w io.Writer, entries []cm.Entry) error {
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Decode reads entries written by Encode from r.
+func (GobCodec) Decode(r io.Reader) ([]cm.Entry, error) {
+	var entries []cm.Entry
+	err := gob.NewDecoder(r).Decode(&entries)
+	return entries, err
+}
+
+// JSONCodec encodes entries with encoding/json. Keys and values come back
+// as the types encoding/json decodes into an interface value, so numbers
+// are read as float64 and structs as map[string]interface{}.
+type JSONCodec struct{}
+
+// Encode writes entries to w.
+func (JSONCodec) Encode(w io.Writer, entries []cm.Entry) error {
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// Decode reads entries written by Encode from r.
+func (JSONCodec) Decode(r io.Reader) ([]cm.Entry, error) {
+	var entries []cm.Entry
+	err := json.NewDecoder(r).Decode(&entries)
+	return entries, err
+}
+
+// WithCodec makes WriteTo and ReadFrom use c instead of the default
+// frame-per-entry gob stream. A nil codec restores the default. It
+// returns the cache to allow chaining.
+func (lru *LRU) WithCodec(c Codec) *LRU {
+	lru.codec = c
+	return lru
+}
+
+func (lru *LRU) writeCodec(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := lru.codec.Encode(cw, lru.Snapshot())
+	return cw.n, err
+}
+
+func (lru *LRU) readCodec(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	entries, err := lru.codec.Decode(cr)
+	if err != nil {
+		return cr.n, err
+	}
+
+	lru.prepareLoad()
+	for _, kv := range entries {
+		lru.load(kv)
+	}
+	lru.checkSize()
+	return cr.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	m, err := cw.w.Write(p)
+	cw.n += int64(m)
+	return m, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	m, err := cr.r.Read(p)
+	cr.n += int64(m)
+	return m, err
+}
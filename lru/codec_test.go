@@ -0,0 +1,49 @@
+package lru_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestLRUCodecRoundTrip(t *testing.T) {
+	codecs := []struct {
+		name  string
+		codec lru.Codec
+	}{
+		{"gob", lru.GobCodec{}},
+		{"json", lru.JSONCodec{}},
+	}
+	for _, tt := range codecs {
+		src := lru.NewLRU(0).WithCodec(tt.codec)
+		src.Add("a", "one")
+		src.Add("b", "two")
+		src.Add("c", "three")
+		src.Get("a")
+
+		var buf bytes.Buffer
+		written, err := src.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to write: %v", tt.name, err)
+		}
+		if written != int64(buf.Len()) {
+			t.Fatalf("%s: wrote %d bytes, reported %d", tt.name, buf.Len(), written)
+		}
+
+		dst := lru.NewLRU(2).WithCodec(tt.codec)
+		read, err := dst.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("%s: failed to read: %v", tt.name, err)
+		}
+		if read != written {
+			t.Fatalf("%s: read %d bytes, wrote %d", tt.name, read, written)
+		}
+
+		// the least recently used entry does not fit
+		if want := src.Snapshot()[:2]; !reflect.DeepEqual(dst.Snapshot(), want) {
+			t.Fatalf("%s: got %v; want %v", tt.name, dst.Snapshot(), want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package lru
+
+import (
+	"time"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// Config groups the tunables of an LRU so that a cache can be switched
+// between profiles, such as low-memory and high-throughput, in one step.
+// Each field has the meaning of the LRU field of the same name.
+type Config struct {
+	MaxEntries      int
+	ZeroMeansEmpty  bool
+	EvictBatch      int
+	NoReadPromote   bool
+	PromoteCooldown time.Duration
+	CanEvict        func(k cm.Key, v cm.Value) bool
+	MinResidency    func(v cm.Value) time.Time
+	OnEvicted       func(k cm.Key, v cm.Value)
+}
+
+// Config returns the current tunables of the cache.
+func (lru *LRU) Config() Config {
+	return Config{
+		MaxEntries:      lru.MaxEntries,
+		ZeroMeansEmpty:  lru.ZeroMeansEmpty,
+		EvictBatch:      lru.EvictBatch,
+		NoReadPromote:   lru.NoReadPromote,
+		PromoteCooldown: lru.PromoteCooldown,
+		CanEvict:        lru.CanEvict,
+		MinResidency:    lru.MinResidency,
+		OnEvicted:       lru.OnEvicted,
+	}
+}
+
+// ApplyConfig replaces all tunables of the cache with those of c, then
+// evicts the least recently used entries until the cache fits the new
+// limit, reporting them to the new OnEvicted. As with Add, pinned and
+// vetoed entries are kept even if the cache stays over the limit.
+// MaxEntries must not be negative.
+func (lru *LRU) ApplyConfig(c Config) {
+	if c.MaxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
+
+	lru.MaxEntries = c.MaxEntries
+	lru.ZeroMeansEmpty = c.ZeroMeansEmpty
+	lru.EvictBatch = c.EvictBatch
+	lru.NoReadPromote = c.NoReadPromote
+	lru.PromoteCooldown = c.PromoteCooldown
+	lru.CanEvict = c.CanEvict
+	lru.MinResidency = c.MinResidency
+	lru.OnEvicted = c.OnEvicted
+
+	if (lru.MaxEntries > 0) || lru.ZeroMeansEmpty {
+		lru.TrimToSize(lru.MaxEntries)
+	}
+}
@@ -0,0 +1,61 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestLRUApplyConfig(t *testing.T) {
+	var evictedBy []string
+	profile := func(name string, maxEntries int) lru.Config {
+		return lru.Config{
+			MaxEntries: maxEntries,
+			OnEvicted: func(k cm.Key, v cm.Value) {
+				evictedBy = append(evictedBy, name)
+			},
+		}
+	}
+	highPerf, lowMem := profile("high_perf", 4), profile("low_mem", 2)
+
+	c := lru.NewLRU(0)
+	c.ApplyConfig(highPerf)
+	for i := 0; i < 5; i++ {
+		c.Add(i, i)
+	}
+	if c.Len() != 4 || len(evictedBy) != 1 || evictedBy[0] != "high_perf" {
+		t.Fatalf("TestLRUApplyConfig failed under high_perf: len = %d, evictions = %v", c.Len(), evictedBy)
+	}
+
+	c.ApplyConfig(lowMem)
+	if c.Len() != 2 || len(evictedBy) != 3 || evictedBy[2] != "low_mem" {
+		t.Fatalf("TestLRUApplyConfig failed switching to low_mem: len = %d, evictions = %v", c.Len(), evictedBy)
+	}
+	if keys := c.Keys(); keys[0] != 4 || keys[1] != 3 {
+		t.Fatalf("TestLRUApplyConfig kept the wrong entries: %v", keys)
+	}
+	if cfg := c.Config(); cfg.MaxEntries != 2 {
+		t.Fatalf("TestLRUApplyConfig failed.  Expected MaxEntries %d, got %d", 2, cfg.MaxEntries)
+	}
+
+	c.ApplyConfig(highPerf)
+	c.Add(5, 5)
+	c.Add(6, 6)
+	c.Add(7, 7)
+	if c.Len() != 4 || evictedBy[len(evictedBy)-1] != "high_perf" {
+		t.Fatalf("TestLRUApplyConfig failed switching back: len = %d, evictions = %v", c.Len(), evictedBy)
+	}
+}
+
+func TestLRUApplyConfigZeroValue(t *testing.T) {
+	c := lru.NewLRU(0)
+	c.ApplyConfig(lru.Config{MaxEntries: 2})
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("TestLRUApplyConfigZeroValue turned off read promotion")
+	}
+}
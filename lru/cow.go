@@ -0,0 +1,102 @@
+package lru
+
+import (
+	"sync"
+	"sync/atomic"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// COWCache 是写时复制（copy-on-write）的缓存，没有淘汰策略。
+// 读操作直接访问一份不可变的快照，通过 atomic.Value 加载，完全无锁；
+// 写操作在互斥锁内复制整份快照、修改后再原子地替换。
+//
+// 适用场景：
+// 数据量小、读多写少的缓存，例如配置。每次写入的代价与缓存大小成正比，
+// 不适合大缓存或频繁写入。
+//
+
+type COWCache struct {
+	mu       sync.Mutex
+	snapshot atomic.Value // map[cm.Key]cm.Value
+}
+
+// NewCOWCache creates a new Cache.
+func NewCOWCache() *COWCache {
+	return &COWCache{}
+}
+
+// Add adds a value to the cache.
+func (cow *COWCache) Add(k cm.Key, v cm.Value) {
+	cow.mu.Lock()
+	defer cow.mu.Unlock()
+
+	m := cow.clone(1)
+	m[k] = v
+	cow.snapshot.Store(m)
+}
+
+// Get looks up a key's value from the cache without locking.
+func (cow *COWCache) Get(k cm.Key) (v cm.Value, ok bool) {
+	v, ok = cow.load()[k]
+	return v, ok
+}
+
+// Peek looks up a key's value from the cache. Reads never change a
+// COWCache, so it is the same as Get.
+func (cow *COWCache) Peek(k cm.Key) (v cm.Value, ok bool) {
+	return cow.Get(k)
+}
+
+// Keys returns all keys of the current snapshot in no particular order.
+func (cow *COWCache) Keys() []cm.Key {
+	m := cow.load()
+	keys := make([]cm.Key, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Remove removes the provided key from the cache.
+func (cow *COWCache) Remove(k cm.Key) {
+	cow.mu.Lock()
+	defer cow.mu.Unlock()
+
+	if _, ok := cow.Get(k); !ok {
+		return
+	}
+	m := cow.clone(0)
+	delete(m, k)
+	cow.snapshot.Store(m)
+}
+
+// Len returns the number of items in the cache.
+func (cow *COWCache) Len() int {
+	return len(cow.load())
+}
+
+// Clear purges all entries from the cache.
+func (cow *COWCache) Clear() {
+	cow.mu.Lock()
+	defer cow.mu.Unlock()
+
+	cow.snapshot.Store(map[cm.Key]cm.Value(nil))
+}
+
+// clone copies the current snapshot, leaving room for extra more entries.
+func (cow *COWCache) clone(extra int) map[cm.Key]cm.Value {
+	old := cow.load()
+	m := make(map[cm.Key]cm.Value, len(old)+extra)
+	for k, v := range old {
+		m[k] = v
+	}
+	return m
+}
+
+// load returns the current snapshot, which is nil before the first Add
+// and after Clear.
+func (cow *COWCache) load() map[cm.Key]cm.Value {
+	m, _ := cow.snapshot.Load().(map[cm.Key]cm.Value)
+	return m
+}
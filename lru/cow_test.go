@@ -0,0 +1,90 @@
+package lru_test
+
+import (
+	"sync"
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestCOWCacheGet(t *testing.T) {
+	getTests := []struct {
+		name       string
+		keyToAdd   interface{}
+		keyToGet   interface{}
+		expectedOk bool
+	}{
+		{"string_hit", "myKey", "myKey", true},
+		{"string_miss", "myKey", "nonsense", false},
+	}
+	for _, tt := range getTests {
+		cow := lru.NewCOWCache()
+		cow.Add(tt.keyToAdd, 1234)
+		val, ok := cow.Get(tt.keyToGet)
+		if ok != tt.expectedOk {
+			t.Fatalf("%s: cache hit = %v; want %v", tt.name, ok, !ok)
+		} else if ok && val != 1234 {
+			t.Fatalf("%s expected get to return 1234 but got %v", tt.name, val)
+		}
+	}
+}
+
+func TestCOWCacheRemove(t *testing.T) {
+	cow := lru.NewCOWCache()
+	cow.Add("myKey", 1234)
+	cow.Add("other", 5678)
+
+	cow.Remove("myKey")
+	if _, ok := cow.Get("myKey"); ok {
+		t.Fatal("TestCOWCacheRemove returned a removed entry")
+	}
+	if n := cow.Len(); n != 1 {
+		t.Fatalf("TestCOWCacheRemove failed.  Expected len %d, got %d", 1, n)
+	}
+
+	cow.Clear()
+	if n := cow.Len(); n != 0 {
+		t.Fatalf("TestCOWCacheRemove failed.  Expected len %d, got %d", 0, n)
+	}
+}
+
+func TestCOWCacheConcurrent(t *testing.T) {
+	cow := lru.NewCOWCache()
+	cow.Add("myKey", 0)
+
+	var wg sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if _, ok := cow.Get("myKey"); !ok {
+					t.Error("TestCOWCacheConcurrent lost an entry that is never removed")
+					return
+				}
+				cow.Get(i)
+				cow.Len()
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cow.Add(i, i)
+			cow.Add("myKey", i)
+			if i%2 == 0 {
+				cow.Remove(i)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if n := cow.Len(); n != 51 {
+		t.Fatalf("TestCOWCacheConcurrent failed.  Expected len %d, got %d", 51, n)
+	}
+	if val, ok := cow.Get("myKey"); !ok || val != 99 {
+		t.Fatalf("TestCOWCacheConcurrent failed.  Expected %d, got %v", 99, val)
+	}
+}
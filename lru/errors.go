@@ -0,0 +1,11 @@
+package lru
+
+import "errors"
+
+// ErrKeyNotFound is returned, wrapped with the missing key, when a
+// required key is not in the cache.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrFrameTooLarge is returned, wrapped with the frame length, when
+// ReadFrom meets a frame longer than MaxFrameSize.
+var ErrFrameTooLarge = errors.New("frame too large")
@@ -0,0 +1,51 @@
+package lru
+
+import "time"
+
+// SetNow replaces the package clock and returns a function restoring it.
+func SetNow(f func() time.Time) (restore func()) {
+	old := now
+	now = f
+	return func() { now = old }
+}
+
+// HistoryKeys returns the keys in Q-history from the most recently evicted.
+func (mq *LRUMQ) HistoryKeys() []interface{} {
+	var keys []interface{}
+	for e := mq.history.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*mqRecord).k)
+	}
+	return keys
+}
+
+// CorruptMap drops k from the map while leaving it in the list.
+func (lru *LRU) CorruptMap(k interface{}) {
+	delete(lru.cache, k)
+}
+
+// CorruptElement points the map entry of k at the list element of other.
+func (lru *LRU) CorruptElement(k, other interface{}) {
+	lru.cache[k] = lru.cache[other]
+}
+
+// CorruptMap drops k from the map while leaving it in the list.
+func (lruk *LRUK) CorruptMap(k interface{}) {
+	delete(lruk.cache, k)
+}
+
+// CorruptHistory drops k from the access counts while leaving it in the
+// history list.
+func (lruk *LRUK) CorruptHistory(k interface{}) {
+	delete(lruk.count, k)
+}
+
+// CorruptDuplicate adds the fifo entry of k to the lru queue as well.
+func (lru2q *LRU2Q) CorruptDuplicate(k interface{}) {
+	kv := lru2q.qcount[k].Value
+	lru2q.cache[k] = lru2q.ll.PushFront(kv)
+}
+
+// HistoryLen returns the number of uncached keys whose accesses are counted.
+func (lruk *LRUK) HistoryLen() int {
+	return len(lruk.count)
+}
@@ -0,0 +1,200 @@
+package lru
+
+import (
+	"container/heap"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// GDSF（Greedy Dual Size Frequency）算法在淘汰数据时同时考虑访问频率和数据的代价（例如重新计算或读取的开销），
+// 其核心思想是“优先保留访问多、代价高的数据”。
+// 每个数据的优先级为 H = L + 频率 × 代价，其中 L 是一个随淘汰而增长的时钟：
+//    1. 需要淘汰数据时，淘汰优先级最小的数据，并将 L 设置为它的优先级；
+//    2. 新插入或被访问的数据按当前的 L 重新计算优先级。
+// L 使得长时间未被访问的数据优先级逐渐落后，从而避免过去的热点数据永远占据缓存。
+//
+// 命中率：
+// 当数据的代价差异较大时，GDSF以命中率为代价换取更低的总代价。
+//
+// 复杂度：
+// 需要维护一个按优先级排序的最小堆。
+//
+// 代价：
+// 命中和插入时都需要调整堆，时间复杂度为O(log n)。
+//
+
+type GDSFCache struct {
+	MaxEntries int
+
+	// CostFunc returns the cost of losing an entry, computed when the
+	// entry is added or updated. If nil, every entry costs 1.
+	CostFunc func(k cm.Key, v cm.Value) float64
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(k cm.Key, v cm.Value)
+
+	clock float64
+	pq    gdsfQueue
+	cache map[cm.Key]*gdsfItem
+}
+
+type gdsfItem struct {
+	cm.Entry
+
+	freq     int
+	cost     float64
+	priority float64
+	index    int
+}
+
+// NewGDSFCache creates a new Cache.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
+func NewGDSFCache(maxEntries int, costFunc func(k cm.Key, v cm.Value) float64) *GDSFCache {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
+	return &GDSFCache{
+		MaxEntries: maxEntries,
+		CostFunc:   costFunc,
+		cache:      make(map[cm.Key]*gdsfItem),
+	}
+}
+
+// Add adds a value to the cache.
+func (gdsf *GDSFCache) Add(k cm.Key, v cm.Value) {
+	strictCheck("GDSFCache", gdsf.cache != nil, gdsf.MaxEntries >= 0)
+
+	if gdsf.cache == nil {
+		gdsf.cache = make(map[cm.Key]*gdsfItem)
+	}
+
+	if it, ok := gdsf.cache[k]; ok {
+		it.V = v
+		it.cost = gdsf.cost(k, v)
+		gdsf.touch(it)
+		return
+	}
+
+	if (gdsf.MaxEntries > 0) && (len(gdsf.pq) >= gdsf.MaxEntries) {
+		gdsf.RemoveVictim()
+	}
+
+	it := &gdsfItem{Entry: cm.Entry{K: k, V: v}, freq: 1, cost: gdsf.cost(k, v)}
+	it.priority = gdsf.clock + it.cost
+	heap.Push(&gdsf.pq, it)
+	gdsf.cache[k] = it
+}
+
+// Get looks up a key's value from the cache.
+func (gdsf *GDSFCache) Get(k cm.Key) (v cm.Value, ok bool) {
+	strictCheck("GDSFCache", gdsf.cache != nil, gdsf.MaxEntries >= 0)
+
+	if it, hit := gdsf.cache[k]; hit {
+		gdsf.touch(it)
+		return it.V, true
+	}
+	return nil, false
+}
+
+// Peek looks up a key's value from the cache without counting an access.
+func (gdsf *GDSFCache) Peek(k cm.Key) (v cm.Value, ok bool) {
+	if it, hit := gdsf.cache[k]; hit {
+		return it.V, true
+	}
+	return nil, false
+}
+
+// Keys returns all keys in no particular order.
+func (gdsf *GDSFCache) Keys() []cm.Key {
+	keys := make([]cm.Key, 0, len(gdsf.cache))
+	for k := range gdsf.cache {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Remove removes the provided key from the cache.
+func (gdsf *GDSFCache) Remove(k cm.Key) {
+	if it, hit := gdsf.cache[k]; hit {
+		heap.Remove(&gdsf.pq, it.index)
+		delete(gdsf.cache, k)
+	}
+}
+
+// RemoveVictim removes the entry with the lowest priority, advancing the
+// clock to its priority as a capacity eviction would, and returns it,
+// calling OnEvicted.
+func (gdsf *GDSFCache) RemoveVictim() (k cm.Key, v cm.Value, ok bool) {
+	if len(gdsf.pq) == 0 {
+		return nil, nil, false
+	}
+
+	it := heap.Pop(&gdsf.pq).(*gdsfItem)
+	delete(gdsf.cache, it.K)
+	gdsf.clock = it.priority
+	if gdsf.OnEvicted != nil {
+		gdsf.OnEvicted(it.K, it.V)
+	}
+	return it.K, it.V, true
+}
+
+// Len returns the number of items in the cache.
+func (gdsf *GDSFCache) Len() int {
+	return len(gdsf.pq)
+}
+
+// Clear purges all entries from the cache.
+func (gdsf *GDSFCache) Clear() {
+	if gdsf.OnEvicted != nil {
+		for _, it := range gdsf.cache {
+			gdsf.OnEvicted(it.K, it.V)
+		}
+	}
+
+	gdsf.clock = 0
+	gdsf.pq = nil
+	gdsf.cache = nil
+}
+
+func (gdsf *GDSFCache) cost(k cm.Key, v cm.Value) float64 {
+	if gdsf.CostFunc == nil {
+		return 1
+	}
+	return gdsf.CostFunc(k, v)
+}
+
+// touch records an access to it and recomputes its priority.
+func (gdsf *GDSFCache) touch(it *gdsfItem) {
+	it.freq += 1
+	it.priority = gdsf.clock + float64(it.freq)*it.cost
+	heap.Fix(&gdsf.pq, it.index)
+}
+
+// gdsfQueue is a min-heap of items ordered by priority.
+type gdsfQueue []*gdsfItem
+
+func (pq gdsfQueue) Len() int { return len(pq) }
+
+func (pq gdsfQueue) Less(i, j int) bool { return pq[i].priority < pq[j].priority }
+
+func (pq gdsfQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *gdsfQueue) Push(x any) {
+	it := x.(*gdsfItem)
+	it.index = len(*pq)
+	*pq = append(*pq, it)
+}
+
+func (pq *gdsfQueue) Pop() any {
+	old := *pq
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*pq = old[:n-1]
+	return it
+}
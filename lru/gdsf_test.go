@@ -0,0 +1,108 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestGDSFCacheGet(t *testing.T) {
+	getTests := []struct {
+		name       string
+		keyToAdd   interface{}
+		keyToGet   interface{}
+		expectedOk bool
+	}{
+		{"string_hit", "myKey", "myKey", true},
+		{"string_miss", "myKey", "nonsense", false},
+	}
+	for _, tt := range getTests {
+		gdsf := lru.NewGDSFCache(0, nil)
+		gdsf.Add(tt.keyToAdd, 1234)
+		val, ok := gdsf.Get(tt.keyToGet)
+		if ok != tt.expectedOk {
+			t.Fatalf("%s: cache hit = %v; want %v", tt.name, ok, !ok)
+		} else if ok && val != 1234 {
+			t.Fatalf("%s expected get to return 1234 but got %v", tt.name, val)
+		}
+	}
+}
+
+func TestGDSFCacheRemove(t *testing.T) {
+	gdsf := lru.NewGDSFCache(0, nil)
+	gdsf.Add("myKey", 1234)
+	gdsf.Add("other", 5678)
+
+	gdsf.Remove("myKey")
+	if _, ok := gdsf.Get("myKey"); ok {
+		t.Fatal("TestGDSFCacheRemove returned a removed entry")
+	}
+	if n := gdsf.Len(); n != 1 {
+		t.Fatalf("TestGDSFCacheRemove failed.  Expected len %d, got %d", 1, n)
+	}
+}
+
+func TestGDSFCacheCost(t *testing.T) {
+	costs := map[cm.Key]float64{"expensive": 10}
+	cost := func(k cm.Key, v cm.Value) float64 {
+		if c, ok := costs[k]; ok {
+			return c
+		}
+		return 1
+	}
+
+	var evicted []interface{}
+	gdsf := lru.NewGDSFCache(2, cost)
+	gdsf.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	// expensive: priority 2 × 10 = 20; cheap: priority 5 × 1 = 5
+	gdsf.Add("expensive", 1)
+	gdsf.Add("cheap", 2)
+	gdsf.Get("expensive")
+	for i := 0; i < 4; i++ {
+		gdsf.Get("cheap")
+	}
+
+	gdsf.Add("new", 3)
+	if len(evicted) != 1 || evicted[0] != "cheap" {
+		t.Fatalf("TestGDSFCacheCost failed.  Expected evictions [cheap], got %v", evicted)
+	}
+	if _, ok := gdsf.Get("expensive"); !ok {
+		t.Fatal("TestGDSFCacheCost evicted the expensive entry")
+	}
+
+	// the clock is now 5, so "new" has priority 5 + 1 = 6 and is the
+	// cheapest entry to lose next
+	gdsf.Add("newer", 4)
+	if len(evicted) != 2 || evicted[1] != "new" {
+		t.Fatalf("TestGDSFCacheCost failed.  Expected evictions [cheap new], got %v", evicted)
+	}
+}
+
+func TestGDSFCacheRemoveVictim(t *testing.T) {
+	var evicted []interface{}
+	gdsf := lru.NewGDSFCache(0, nil)
+	gdsf.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+	if _, _, ok := gdsf.RemoveVictim(); ok {
+		t.Fatal("TestGDSFCacheRemoveVictim removed an entry from an empty cache")
+	}
+
+	gdsf.Add("frequent", 1)
+	gdsf.Add("rare", 2)
+	gdsf.Get("frequent")
+
+	if k, v, ok := gdsf.RemoveVictim(); !ok || k != "rare" || v != 2 {
+		t.Fatalf("TestGDSFCacheRemoveVictim failed.  Expected (rare, 2), got (%v, %v)", k, v)
+	}
+	if len(evicted) != 1 || evicted[0] != "rare" {
+		t.Fatalf("TestGDSFCacheRemoveVictim failed.  Expected evictions [rare], got %v", evicted)
+	}
+	if n := gdsf.Len(); n != 1 {
+		t.Fatalf("TestGDSFCacheRemoveVictim failed.  Expected len %d, got %d", 1, n)
+	}
+}
@@ -0,0 +1,89 @@
+package lru
+
+import (
+	"container/list"
+	"fmt"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// CheckInvariants reports whether the internal state of the cache is
+// consistent: the map and the list hold the same number of entries, and
+// every map element is in the list and holds its own key. It returns a
+// descriptive error for the first violation found, and is meant for tests.
+func (lru *LRU) CheckInvariants() error {
+	return checkList("LRU", lru.ll, lru.cache, func(e *list.Element) cm.Key {
+		return e.Value.(*lruEntry).K
+	})
+}
+
+// CheckInvariants reports whether the internal state of the cache is
+// consistent, as LRU.CheckInvariants does for the cache and for the access
+// history, and also that no cached key is still counted in the history.
+func (lruk *LRUK) CheckInvariants() error {
+	if err := checkList("LRUK", lruk.ll, lruk.cache, entryKey); err != nil {
+		return err
+	}
+	err := checkList("LRUK history", lruk.history, lruk.count, func(e *list.Element) cm.Key {
+		return e.Value.(*lrukCount).k
+	})
+	if err != nil {
+		return err
+	}
+
+	for k := range lruk.count {
+		if _, ok := lruk.cache[k]; ok {
+			return fmt.Errorf("LRUK: key %v is both cached and in the history", k)
+		}
+	}
+	return nil
+}
+
+// CheckInvariants reports whether the internal state of the cache is
+// consistent, as LRU.CheckInvariants does for each queue, and also that no
+// key is held by both queues.
+func (lru2q *LRU2Q) CheckInvariants() error {
+	if err := checkList("LRU2Q lru queue", lru2q.ll, lru2q.cache, entryKey); err != nil {
+		return err
+	}
+	if err := checkList("LRU2Q fifo queue", lru2q.fifo, lru2q.qcount, entryKey); err != nil {
+		return err
+	}
+
+	for k := range lru2q.qcount {
+		if _, ok := lru2q.cache[k]; ok {
+			return fmt.Errorf("LRU2Q: key %v is in both queues", k)
+		}
+	}
+	return nil
+}
+
+func entryKey(e *list.Element) cm.Key {
+	return e.Value.(*cm.Entry).K
+}
+
+func checkList(name string, ll *list.List, cache map[cm.Key]*list.Element, key func(e *list.Element) cm.Key) error {
+	if cache == nil {
+		if ll != nil && ll.Len() != 0 {
+			return fmt.Errorf("%s: list holds %d entries without a map", name, ll.Len())
+		}
+		return nil
+	}
+	if ll == nil {
+		return fmt.Errorf("%s: map holds %d entries without a list", name, len(cache))
+	}
+
+	if len(cache) != ll.Len() {
+		return fmt.Errorf("%s: map holds %d entries, list holds %d", name, len(cache), ll.Len())
+	}
+
+	for e := ll.Front(); e != nil; e = e.Next() {
+		k := key(e)
+		if ee, ok := cache[k]; !ok {
+			return fmt.Errorf("%s: key %v is in the list but not the map", name, k)
+		} else if ee != e {
+			return fmt.Errorf("%s: map element for key %v is not its list element", name, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	c := lru.NewLRU(0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	c2 := lru.NewLRU(0)
+	c2.Add("a", 1)
+	c2.Add("b", 2)
+
+	lruk := lru.NewLRUK(0, 1)
+	lruk.Add("a", 1)
+	lruk.Add("b", 2)
+
+	lrukHistory := lru.NewLRUK(0, 2)
+	lrukHistory.Add("a", 1)
+	lrukHistory.Add("b", 2)
+	lrukHistory.Add("b", 2)
+
+	lru2q := lru.NewLRU2Q(0)
+	lru2q.Add("a", 1)
+	lru2q.Add("b", 2)
+	lru2q.Add("b", 2)
+
+	invariantTests := []struct {
+		name    string
+		check   func() error
+		corrupt func()
+	}{
+		{"lru_map", c.CheckInvariants, func() { c.CorruptMap("a") }},
+		{"lru_element", c2.CheckInvariants, func() { c2.CorruptElement("a", "b") }},
+		{"lruk_map", lruk.CheckInvariants, func() { lruk.CorruptMap("a") }},
+		{"lruk_history", lrukHistory.CheckInvariants, func() { lrukHistory.CorruptHistory("a") }},
+		{"lru2q_duplicate", lru2q.CheckInvariants, func() { lru2q.CorruptDuplicate("a") }},
+	}
+	for _, tt := range invariantTests {
+		if err := tt.check(); err != nil {
+			t.Fatalf("%s: unexpected error before corruption: %v", tt.name, err)
+		}
+		tt.corrupt()
+		if err := tt.check(); err == nil {
+			t.Fatalf("%s: corruption not detected", tt.name)
+		}
+	}
+}
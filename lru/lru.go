@@ -2,6 +2,11 @@ package lru
 
 import (
 	"container/list"
+	"container/ring"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
 
 	cm "goalgutil/macros/cache_macro"
 )
@@ -26,28 +31,162 @@ import (
 //
 
 type LRU struct {
+	// MaxEntries is the maximum number of cache entries before an item
+	// is evicted. Entries pinned by Acquire are never evicted; if every
+	// entry is pinned, the cache grows past MaxEntries until some are
+	// released.
 	MaxEntries int
 
+	// ZeroMeansEmpty changes the meaning of a zero MaxEntries from "no
+	// limit" to "no room": every Add is evicted straight away, reporting
+	// the entry to OnEvicted, and the cache stays empty. This is handy
+	// for switching caching off through configuration.
+	ZeroMeansEmpty bool
+
+	// EvictBatch is the number of entries evicted at once when the cache
+	// is full, amortizing eviction work under sustained inserts. Values
+	// below 1 evict a single entry.
+	EvictBatch int
+
+	// NoReadPromote stops Get from moving an entry to the front of the
+	// cache: reads leave the eviction order untouched, so entries are
+	// evicted in insertion order. Unlike a dedicated FIFO cache it can be
+	// toggled at any time.
+	NoReadPromote bool
+
+	// PromoteCooldown, if positive, limits how often Get moves an entry to
+	// the front: only the first read in each PromoteCooldown window since
+	// the entry was last moved promotes it, so bursts of reads of a hot key
+	// do not churn the list. Add always promotes.
+	PromoteCooldown time.Duration
+
+	// CanEvict optionally vetoes capacity evictions. When it returns
+	// false for the least recently used entry, the next oldest is tried
+	// instead. Vetoed entries are treated like pinned ones: if every
+	// entry is vetoed or pinned, nothing is evicted and the cache grows
+	// past MaxEntries. Remove, Clear and EvictOlderThan ignore it.
+	CanEvict func(k cm.Key, v cm.Value) bool
+
+	// MinResidency optionally returns the time before which a value must
+	// not be evicted for capacity, such as a minimum cache duration carried
+	// by the value. Protected entries are skipped like vetoed ones, and
+	// the cache grows past MaxEntries if every entry is protected.
+	MinResidency func(v cm.Value) time.Time
+
+	// Spill optionally receives entries evicted from the cache, and
+	// serves Gets that miss the cache; see Spill.
+	Spill Spill
+
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	OnEvicted func(k cm.Key, v cm.Value)
 
+	// OnEvictedSampled optionally specifies a callback function to be
+	// executed for a random sample of the entries purged from the cache,
+	// each one chosen with probability SampleRate, for callers that only
+	// need a representative view such as logging.
+	OnEvictedSampled func(k cm.Key, v cm.Value)
+	SampleRate       float64
+
+	// OnEvictedBatch optionally specifies a callback function to be
+	// executed with evicted entries in batches of BatchSize, for callers
+	// whose per-entry handling is expensive. Entries purged by Clear are
+	// included, and Clear and FlushEvictions deliver a final, possibly
+	// partial, batch. It may be set alongside OnEvicted, in which case
+	// both are called. A BatchSize below 1 delivers every entry at once.
+	OnEvictedBatch func(entries []cm.Entry)
+	BatchSize      int
+
+	// OnClear optionally specifies a callback function to be executed
+	// once a Clear has completed, after any OnEvicted calls.
+	OnClear func()
+
+	// OnPressure optionally specifies a callback function to be executed
+	// when the cache is full and the miss rate of recent lookups exceeds
+	// PressureThreshold, so that callers can apply backpressure upstream.
+	// Lookups are counted in windows of at least PressureInterval, the
+	// first of which opens at the first lookup; a miss that closes a window
+	// checks the condition, so OnPressure fires at most once per
+	// PressureInterval.
+	OnPressure        func(fillRatio float64, recentMissRate float64)
+	PressureThreshold float64
+	PressureInterval  time.Duration
+
+	// Rand optionally specifies the source of randomness used by
+	// SampleKeys and OnEvictedSampled. If nil, the default math/rand source is used.
+	Rand *rand.Rand
+
 	ll    *list.List
 	cache map[cm.Key]*list.Element
+	refs  map[cm.Key]int
+	meta  map[cm.Key]any
+
+	watchers map[cm.Key][]chan struct{}
+	oplog    *ring.Ring
+	codec    Codec
+
+	evictionLog []cm.Key
+	pending     []cm.Entry
+
+	sizeStep int
+	sizeNext int
+	onSize   func(size int)
+
+	windowStart  time.Time
+	windowHits   int
+	windowMisses int
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// lruEntry is the value held by each element of the list.
+type lruEntry struct {
+	cm.Entry
+
+	// accessed is when the entry was last added or read.
+	accessed time.Time
+
+	// promoted is when the entry was last moved to the front.
+	promoted time.Time
+
+	// hits counts the Gets that found the entry.
+	hits int
+
+	// sticky entries survive Clear; see AddSticky.
+	sticky bool
 }
 
 // New creates a new Cache.
-// If maxEntries is zero, the cache has no limit.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
 func NewLRU(maxEntries int) *LRU {
+	return NewLRUWithCapacity(maxEntries, 0)
+}
+
+// NewLRUWithCapacity creates a new Cache whose internal map is pre-sized
+// to hold initialCap entries, avoiding repeated rehashing while it fills.
+func NewLRUWithCapacity(maxEntries, initialCap int) *LRU {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
 	return &LRU{
 		MaxEntries: maxEntries,
 		ll:         list.New(),
-		cache:      make(map[cm.Key]*list.Element),
+		cache:      make(map[cm.Key]*list.Element, initialCap),
 	}
 }
 
 // Add adds a value to the cache.
 func (lru *LRU) Add(k cm.Key, v cm.Value) {
+	strictCheck("LRU", lru.cache != nil, lru.MaxEntries >= 0)
+	lru.logOp("add", k)
+
+	if lru.ZeroMeansEmpty && (lru.MaxEntries == 0) {
+		lru.reportEvicted(k, v)
+		return
+	}
+
 	if lru.cache == nil {
 		// `make` may fail
 		lru.cache = make(map[cm.Key]*list.Element)
@@ -56,42 +195,337 @@ func (lru *LRU) Add(k cm.Key, v cm.Value) {
 
 	if ee, ok := lru.cache[k]; ok {
 		lru.ll.MoveToFront(ee)
-		ee.Value.(*cm.Entry).V = v
+		kv := ee.Value.(*lruEntry)
+		t := now()
+		kv.V, kv.accessed, kv.promoted = v, t, t
 		return
 	}
-	if (lru.MaxEntries > 0) && (lru.ll.Len() == lru.MaxEntries) {
-		b := lru.ll.Back()
-		k := b.Value.(*cm.Entry).K
-		lru.ll.Remove(b)
-		delete(lru.cache, k)
+	if (lru.MaxEntries > 0) && (lru.ll.Len() >= lru.MaxEntries) {
+		batch := lru.EvictBatch
+		if batch < 1 {
+			batch = 1
+		}
+		for lru.ll.Len() > lru.MaxEntries-batch {
+			b := lru.victim()
+			if b == nil {
+				break
+			}
+			lru.evict(b)
+		}
 	}
-	ee := lru.ll.PushFront(&cm.Entry{K: k, V: v})
+	t := now()
+	ee := lru.ll.PushFront(&lruEntry{Entry: cm.Entry{K: k, V: v}, accessed: t, promoted: t})
 	lru.cache[k] = ee
+	lru.checkSize()
+}
+
+// AddSticky adds a value to the cache like Add and marks the entry sticky:
+// Clear leaves it in place, while Remove, eviction, ClearAll and the
+// methods replacing the whole contents, RestoreExact and SwapContents,
+// still drop it. A later Add of the same key keeps the mark.
+func (lru *LRU) AddSticky(k cm.Key, v cm.Value) {
+	lru.Add(k, v)
+
+	if ee, ok := lru.cache[k]; ok {
+		ee.Value.(*lruEntry).sticky = true
+	}
+}
+
+// Upsert adds a value to the cache, or, if the key is already cached,
+// stores merge(old, v) in its place and promotes the entry. It returns
+// the value that ends up stored.
+func (lru *LRU) Upsert(k cm.Key, v cm.Value, merge func(old, new cm.Value) cm.Value) cm.Value {
+	if ee, ok := lru.cache[k]; ok {
+		strictCheck("LRU", true, lru.MaxEntries >= 0)
+		lru.logOp("add", k)
+
+		kv := ee.Value.(*lruEntry)
+		t := now()
+		kv.V, kv.accessed, kv.promoted = merge(kv.V, v), t, t
+		lru.ll.MoveToFront(ee)
+		return kv.V
+	}
+
+	lru.Add(k, v)
+	return v
+}
+
+// Increment adds delta in place to a cached integer value and returns the
+// result, keeping the value's original integer type. It returns false if
+// the key is not cached or its value is not an integer. Like Peek, it does
+// not change the entry's recency.
+func (lru *LRU) Increment(k cm.Key, delta int64) (newValue int64, ok bool) {
+	ee, hit := lru.cache[k]
+	if !hit {
+		return 0, false
+	}
+
+	kv := ee.Value.(*lruEntry)
+	switch v := kv.V.(type) {
+	case int:
+		v += int(delta)
+		kv.V, newValue = v, int64(v)
+	case int8:
+		v += int8(delta)
+		kv.V, newValue = v, int64(v)
+	case int16:
+		v += int16(delta)
+		kv.V, newValue = v, int64(v)
+	case int32:
+		v += int32(delta)
+		kv.V, newValue = v, int64(v)
+	case int64:
+		v += delta
+		kv.V, newValue = v, v
+	case uint:
+		v += uint(delta)
+		kv.V, newValue = v, int64(v)
+	case uint8:
+		v += uint8(delta)
+		kv.V, newValue = v, int64(v)
+	case uint16:
+		v += uint16(delta)
+		kv.V, newValue = v, int64(v)
+	case uint32:
+		v += uint32(delta)
+		kv.V, newValue = v, int64(v)
+	case uint64:
+		v += uint64(delta)
+		kv.V, newValue = v, int64(v)
+	default:
+		return 0, false
+	}
+	return newValue, true
+}
+
+// Mutate replaces a cached value in place with fn applied to it, and
+// returns false if the key is not cached. Like Increment, it does not
+// change the entry's recency.
+func (lru *LRU) Mutate(k cm.Key, fn func(v cm.Value) cm.Value) bool {
+	ee, hit := lru.cache[k]
+	if !hit {
+		return false
+	}
+
+	kv := ee.Value.(*lruEntry)
+	kv.V = fn(kv.V)
+	return true
 }
 
 // Get looks up a key's value from the cache.
-func (lru *LRU) Get(k cm.Key) (v any, ok bool) {
+func (lru *LRU) Get(k cm.Key) (v cm.Value, ok bool) {
+	strictCheck("LRU", lru.cache != nil, lru.MaxEntries >= 0)
+	lru.logOp("get", k)
+
 	if lru.cache == nil {
-		return nil, false
+		lru.misses += 1
+		return lru.unspill(k)
 	}
 
 	if ee, hit := lru.cache[k]; hit {
-		lru.ll.MoveToFront(ee)
-		return ee.Value.(*cm.Entry).V, true
+		kv := ee.Value.(*lruEntry)
+		kv.accessed = now()
+		kv.hits += 1
+		lru.hits += 1
+		if !lru.NoReadPromote && (lru.PromoteCooldown <= 0 || kv.accessed.Sub(kv.promoted) >= lru.PromoteCooldown) {
+			lru.ll.MoveToFront(ee)
+			kv.promoted = kv.accessed
+		}
+		if lru.OnPressure != nil {
+			lru.countLookup(true)
+		}
+		return kv.V, true
+	}
+
+	lru.misses += 1
+	if lru.OnPressure != nil {
+		lru.countLookup(false)
+	}
+	return lru.unspill(k)
+}
+
+// Peek looks up a key's value from the cache without updating its recency.
+func (lru *LRU) Peek(k cm.Key) (v cm.Value, ok bool) {
+	if ee, hit := lru.cache[k]; hit {
+		return ee.Value.(*lruEntry).V, true
 	}
 	return nil, false
 }
 
+// Probe reports whether a key is cached, without updating its recency,
+// and how many more entries fit before an eviction, or -1 if the cache
+// has no limit.
+func (lru *LRU) Probe(k cm.Key) (present bool, free int) {
+	_, present = lru.cache[k]
+	if (lru.MaxEntries == 0) && !lru.ZeroMeansEmpty {
+		return present, -1
+	}
+	if free = lru.MaxEntries - lru.Len(); free < 0 {
+		free = 0
+	}
+	return present, free
+}
+
+// MustGet looks up a key's value from the cache and panics if the key
+// is not cached.
+func (lru *LRU) MustGet(k cm.Key) cm.Value {
+	v, ok := lru.Get(k)
+	if !ok {
+		panic(fmt.Sprintf("key %v not found in LRU!", k))
+	}
+	return v
+}
+
+// GetErr looks up a key's value from the cache, returning an error
+// wrapping ErrKeyNotFound if the key is not cached.
+func (lru *LRU) GetErr(k cm.Key) (cm.Value, error) {
+	v, ok := lru.Get(k)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, k)
+	}
+	return v, nil
+}
+
+// Demote moves an entry to the back of the cache, making it the next
+// candidate for eviction. It returns false if the key is not cached.
+func (lru *LRU) Demote(k cm.Key) bool {
+	if lru.cache == nil {
+		return false
+	}
+
+	if ee, hit := lru.cache[k]; hit {
+		lru.ll.MoveToBack(ee)
+		return true
+	}
+	return false
+}
+
 // Remove removes the provided key from the cache.
 func (lru *LRU) Remove(k cm.Key) {
+	lru.logOp("remove", k)
+
 	if lru.cache == nil {
 		return
 	}
 
 	if ee, hit := lru.cache[k]; hit {
-		lru.ll.Remove(ee)
-		delete(lru.cache, k)
+		lru.removeElement(ee)
+	}
+}
+
+// Acquire looks up a key's value from the cache and pins the entry so
+// that it is not evicted until a matching call to Release.
+func (lru *LRU) Acquire(k cm.Key) (v cm.Value, ok bool) {
+	if v, ok = lru.Get(k); !ok {
+		return nil, false
+	}
+
+	if lru.refs == nil {
+		lru.refs = make(map[cm.Key]int)
+	}
+	lru.refs[k] += 1
+	return v, true
+}
+
+// Release unpins an entry previously pinned by Acquire. Once every
+// Acquire has been released the entry may be evicted again.
+func (lru *LRU) Release(k cm.Key) {
+	if n := lru.refs[k]; n > 1 {
+		lru.refs[k] = n - 1
+	} else {
+		delete(lru.refs, k)
+	}
+}
+
+// RemoveOldest removes the least recently used entry and returns it.
+// It behaves like a capacity eviction: pinned entries are skipped and
+// OnEvicted is called for the removed entry.
+func (lru *LRU) RemoveOldest() (k cm.Key, v cm.Value, ok bool) {
+	if lru.cache == nil {
+		return nil, nil, false
+	}
+
+	if b := lru.victim(); b != nil {
+		lru.evict(b)
+		kv := b.Value.(*lruEntry)
+		return kv.K, kv.V, true
+	}
+	return nil, nil, false
+}
+
+// RemoveVictim removes the entry the cache would evict next and returns
+// it, calling OnEvicted. For LRU this is the same as RemoveOldest.
+func (lru *LRU) RemoveVictim() (k cm.Key, v cm.Value, ok bool) {
+	return lru.RemoveOldest()
+}
+
+// Evict removes up to n of the least recently used entries, calling
+// OnEvicted for each, and returns how many were removed. Pinned entries
+// are skipped.
+func (lru *LRU) Evict(n int) int {
+	evicted := 0
+	for ; evicted < n; evicted++ {
+		if _, _, ok := lru.RemoveOldest(); !ok {
+			break
+		}
 	}
+	return evicted
+}
+
+// EvictOlderThan evicts every entry that has not been added or read with
+// Get in the last d, calling OnEvicted for each, and returns how many were
+// removed. Pinned entries are kept. It is a cheap alternative to per-entry
+// expiry for caches that are swept periodically.
+func (lru *LRU) EvictOlderThan(d time.Duration) int {
+	if lru.cache == nil {
+		return 0
+	}
+
+	cutoff := now().Add(-d)
+	evicted := 0
+	for e := lru.ll.Back(); e != nil; {
+		prev := e.Prev()
+		kv := e.Value.(*lruEntry)
+		if kv.accessed.Before(cutoff) && (lru.refs[kv.K] == 0) {
+			lru.evict(e)
+			evicted += 1
+		}
+		e = prev
+	}
+	return evicted
+}
+
+// RetainKeys removes every entry whose key is not in valid, calling
+// OnEvicted for each, and returns how many were removed. It serves
+// invalidation against an upstream's full set of live keys, so pinned
+// entries are removed too and nothing is handed to Spill.
+func (lru *LRU) RetainKeys(valid map[cm.Key]struct{}) int {
+	if lru.cache == nil {
+		return 0
+	}
+
+	removed := 0
+	for e := lru.ll.Back(); e != nil; {
+		prev := e.Prev()
+		kv := e.Value.(*lruEntry)
+		if _, ok := valid[kv.K]; !ok {
+			lru.removeElement(e)
+			lru.reportEvicted(kv.K, kv.V)
+			removed += 1
+		}
+		e = prev
+	}
+	return removed
+}
+
+// TrimToSize evicts the least recently used entries until at most target
+// remain, calling OnEvicted for each, and returns how many were removed.
+// Unlike lowering MaxEntries, it does not change the capacity.
+func (lru *LRU) TrimToSize(target int) int {
+	if n := lru.Len() - target; n > 0 {
+		return lru.Evict(n)
+	}
+	return 0
 }
 
 // Len returns the number of items in the cache.
@@ -103,14 +537,505 @@ func (lru *LRU) Len() int {
 	return lru.ll.Len()
 }
 
-// Remove removes the provided key from the cache.
+// Clear purges all entries from the cache except those added with
+// AddSticky.
 func (lru *LRU) Clear() {
-	if lru.OnEvicted != nil {
-		for _, e := range lru.cache {
-			kv := e.Value.(*cm.Entry)
-			lru.OnEvicted(kv.K, kv.V)
+	lru.logOp("clear", nil)
+
+	r := lru.clearReport()
+	r.report(lru.detachUnsticky())
+}
+
+// ClearAll purges all entries from the cache, sticky ones included.
+func (lru *LRU) ClearAll() {
+	lru.logOp("clear", nil)
+
+	r := lru.clearReport()
+	r.report(lru.detach())
+}
+
+// detachUnsticky drops all entries that are not sticky, like detach, and
+// returns them for reporting. Without sticky entries it is detach.
+func (lru *LRU) detachUnsticky() map[cm.Key]*list.Element {
+	if !lru.hasSticky() {
+		return lru.detach()
+	}
+
+	old := make(map[cm.Key]*list.Element)
+	for e := lru.ll.Front(); e != nil; {
+		next := e.Next()
+		if kv := e.Value.(*lruEntry); !kv.sticky {
+			old[kv.K] = e
+			lru.removeElement(e)
 		}
+		e = next
 	}
+	return old
+}
+
+func (lru *LRU) hasSticky() bool {
+	if lru.cache == nil {
+		return false
+	}
+
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		if e.Value.(*lruEntry).sticky {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearRetain purges all entries like Clear, keeping sticky ones, but keeps
+// the storage of the internal map so that refilling the cache to a similar
+// size does not allocate it again. Clear instead releases everything to
+// the garbage collector, which suits a cache that will stay small or go
+// unused.
+func (lru *LRU) ClearRetain() {
+	lru.logOp("clear", nil)
+
+	r := lru.clearReport()
+	old := lru.detachUnsticky()
+	r.report(old)
+
+	for k := range old {
+		delete(old, k)
+	}
+	if lru.cache == nil && old != nil {
+		lru.cache = old
+		lru.ll = list.New()
+	}
+}
+
+// ClearAsync purges all entries like Clear, keeping sticky ones, so the
+// cache can be used again right away, and reports the old entries to the
+// eviction callbacks and then calls OnClear in a background goroutine. The
+// returned channel is closed once those callbacks have finished. The
+// callbacks in effect at the time of the call are used.
+func (lru *LRU) ClearAsync() <-chan struct{} {
+	r := lru.clearReport()
+	old := lru.detachUnsticky()
+
+	done := make(chan struct{})
+	go func() {
+		r.report(old)
+		close(done)
+	}()
+	return done
+}
+
+// detach drops all entries, firing their watchers, and returns the old
+// cache map for reporting.
+func (lru *LRU) detach() map[cm.Key]*list.Element {
+	for _, chs := range lru.watchers {
+		for _, ch := range chs {
+			close(ch)
+		}
+	}
+
+	old := lru.cache
 	lru.ll = nil
 	lru.cache = nil
+	lru.refs = nil
+	lru.meta = nil
+	lru.watchers = nil
+	return old
+}
+
+// clearReport captures the callbacks, and any evictions still waiting for
+// a batch, at the start of a Clear.
+type clearReport struct {
+	onEvicted      func(k cm.Key, v cm.Value)
+	onSampled      func(k cm.Key, v cm.Value)
+	sampleRate     float64
+	rand           *rand.Rand
+	onEvictedBatch func(entries []cm.Entry)
+	batchSize      int
+	pending        []cm.Entry
+	onClear        func()
+}
+
+func (lru *LRU) clearReport() *clearReport {
+	r := &clearReport{
+		onEvicted:      lru.OnEvicted,
+		onSampled:      lru.OnEvictedSampled,
+		sampleRate:     lru.SampleRate,
+		onEvictedBatch: lru.OnEvictedBatch,
+		batchSize:      lru.BatchSize,
+		pending:        lru.pending,
+		onClear:        lru.OnClear,
+	}
+	lru.pending = nil
+
+	// ClearAsync reports from another goroutine, so the report samples
+	// from its own source instead of sharing Rand with the cache
+	if (lru.OnEvictedSampled != nil) && (lru.Rand != nil) {
+		r.rand = rand.New(rand.NewSource(lru.Rand.Int63()))
+	}
+	return r
+}
+
+// report hands the entries of a cleared cache to the callbacks.
+func (r *clearReport) report(old map[cm.Key]*list.Element) {
+	for _, e := range old {
+		kv := e.Value.(*lruEntry)
+		if r.onEvicted != nil {
+			r.onEvicted(kv.K, kv.V)
+		}
+		if (r.onSampled != nil) && sample(r.rand, r.sampleRate) {
+			r.onSampled(kv.K, kv.V)
+		}
+
+		if r.onEvictedBatch != nil {
+			r.pending = append(r.pending, kv.Entry)
+			if (r.batchSize > 0) && (len(r.pending) >= r.batchSize) {
+				r.onEvictedBatch(r.pending)
+				r.pending = nil
+			}
+		}
+	}
+	if (r.onEvictedBatch != nil) && (len(r.pending) > 0) {
+		r.onEvictedBatch(r.pending)
+	}
+
+	if r.onClear != nil {
+		r.onClear()
+	}
+}
+
+// Keys returns all keys from the most to the least recently used.
+func (lru *LRU) Keys() []cm.Key {
+	if lru.cache == nil {
+		return nil
+	}
+
+	keys := make([]cm.Key, 0, lru.ll.Len())
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*lruEntry).K)
+	}
+	return keys
+}
+
+// Snapshot returns a copy of all entries from the most to the least
+// recently used. The copy reflects the cache at the time of the call and
+// can be iterated while the cache changes; callers sharing the cache
+// between goroutines only need to hold their lock while taking it.
+func (lru *LRU) Snapshot() []cm.Entry {
+	if lru.cache == nil {
+		return nil
+	}
+
+	entries := make([]cm.Entry, 0, lru.ll.Len())
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		entries = append(entries, e.Value.(*lruEntry).Entry)
+	}
+	return entries
+}
+
+// RestoreExact replaces the contents of the cache with entries, given from
+// the most to the least recently used as returned by Snapshot, so that the
+// cache ends up in exactly that recency order. If there are more entries
+// than fit, the most recently used ones are kept. The previous contents,
+// sticky entries included, are purged as by ClearAll.
+func (lru *LRU) RestoreExact(entries []cm.Entry) {
+	lru.ClearAll()
+	lru.ll, lru.cache = lru.build(entries)
+	lru.checkSize()
+}
+
+// SwapContents replaces the contents of the cache with entries, ordered as
+// for RestoreExact, in one step: the new contents are built aside and then
+// installed, so the cache is never observed empty in between. The old
+// entries whose keys are not in entries are reported to the eviction
+// callbacks afterwards. Pins, metadata and watchers carry over for keys
+// present in both.
+func (lru *LRU) SwapContents(entries []cm.Entry) {
+	ll, cache := lru.build(entries)
+	oldll := lru.ll
+	lru.ll, lru.cache = ll, cache
+
+	if oldll == nil {
+		lru.checkSize()
+		return
+	}
+	var dropped []*lruEntry
+	for e := oldll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*lruEntry)
+		if _, ok := cache[kv.K]; !ok {
+			lru.forget(kv.K)
+			dropped = append(dropped, kv)
+		}
+	}
+	for _, kv := range dropped {
+		lru.reportEvicted(kv.K, kv.V)
+	}
+	lru.checkSize()
+}
+
+// build creates a list and map holding entries in order, up to MaxEntries.
+func (lru *LRU) build(entries []cm.Entry) (*list.List, map[cm.Key]*list.Element) {
+	t := now()
+	ll := list.New()
+	cache := make(map[cm.Key]*list.Element, len(entries))
+	for _, kv := range entries {
+		if (lru.MaxEntries > 0 || lru.ZeroMeansEmpty) && (ll.Len() >= lru.MaxEntries) {
+			break
+		}
+		if _, ok := cache[kv.K]; ok {
+			continue
+		}
+		cache[kv.K] = ll.PushBack(&lruEntry{Entry: kv, accessed: t})
+	}
+	return ll, cache
+}
+
+// ForEach calls f for every entry from the most to the least recently
+// used. It iterates over a Snapshot, so f may call back into the cache,
+// for example to Remove entries: such changes affect the live cache but
+// not the entries visited by the current pass.
+func (lru *LRU) ForEach(f func(k cm.Key, v cm.Value)) {
+	for _, kv := range lru.Snapshot() {
+		f(kv.K, kv.V)
+	}
+}
+
+// SortedKeys returns all keys ordered by less rather than by recency.
+// It does not change the recency of any entry.
+func (lru *LRU) SortedKeys(less func(a, b cm.Key) bool) []cm.Key {
+	keys := make([]cm.Key, 0, len(lru.cache))
+	for k := range lru.cache {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
+
+// SampleKeys returns up to n keys chosen uniformly at random, using
+// reservoir sampling over a single pass that does not change recency.
+func (lru *LRU) SampleKeys(n int) []cm.Key {
+	if n <= 0 || lru.cache == nil {
+		return nil
+	}
+
+	intn := rand.Intn
+	if lru.Rand != nil {
+		intn = lru.Rand.Intn
+	}
+
+	sample := make([]cm.Key, 0, n)
+	i := 0
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		k := e.Value.(*lruEntry).K
+		if i < n {
+			sample = append(sample, k)
+		} else if j := intn(i + 1); j < n {
+			sample[j] = k
+		}
+		i += 1
+	}
+	return sample
+}
+
+// SetMeta attaches metadata to a cached entry, replacing any previous
+// metadata. The metadata is dropped when the entry leaves the cache.
+// It returns false if the key is not cached. Recency is not changed.
+func (lru *LRU) SetMeta(k cm.Key, meta any) bool {
+	if _, hit := lru.cache[k]; !hit {
+		return false
+	}
+
+	if lru.meta == nil {
+		lru.meta = make(map[cm.Key]any)
+	}
+	lru.meta[k] = meta
+	return true
+}
+
+// GetMeta returns the metadata attached to a cached entry by SetMeta.
+// Recency is not changed.
+func (lru *LRU) GetMeta(k cm.Key) (meta any, ok bool) {
+	meta, ok = lru.meta[k]
+	return meta, ok
+}
+
+// OnSizeThreshold arranges for fn to be called each time the cache grows
+// to a new multiple of threshold entries, e.g. at 10k, 20k, 30k, ... for a
+// threshold of 10k, to log the growth of an unbounded cache. Each multiple
+// fires at most once, even if the cache shrinks and grows past it again.
+// A threshold below 1 turns the callback off.
+func (lru *LRU) OnSizeThreshold(threshold int, fn func(size int)) {
+	if threshold < 1 {
+		lru.sizeStep, lru.sizeNext, lru.onSize = 0, 0, nil
+		return
+	}
+
+	lru.sizeStep, lru.onSize = threshold, fn
+	lru.sizeNext = (lru.Len()/threshold + 1) * threshold
+}
+
+func (lru *LRU) checkSize() {
+	if (lru.onSize == nil) || (lru.ll.Len() < lru.sizeNext) {
+		return
+	}
+
+	n := lru.ll.Len()
+	lru.sizeNext = (n/lru.sizeStep + 1) * lru.sizeStep
+	lru.onSize(n)
+}
+
+// Watch returns a channel that is closed once the key leaves the cache,
+// whether by eviction, Remove or Clear. If the key is not cached the
+// returned channel is already closed.
+func (lru *LRU) Watch(k cm.Key) <-chan struct{} {
+	ch := make(chan struct{})
+	if _, hit := lru.cache[k]; !hit {
+		close(ch)
+		return ch
+	}
+
+	if lru.watchers == nil {
+		lru.watchers = make(map[cm.Key][]chan struct{})
+	}
+	lru.watchers[k] = append(lru.watchers[k], ch)
+	return ch
+}
+
+// countLookup counts a lookup in the current pressure window, which the
+// first lookup opens, and checks for pressure after a miss.
+func (lru *LRU) countLookup(hit bool) {
+	if lru.windowStart.IsZero() {
+		lru.windowStart = now()
+	}
+
+	if hit {
+		lru.windowHits += 1
+		return
+	}
+	lru.windowMisses += 1
+	lru.checkPressure()
+}
+
+// checkPressure closes the current lookup window if it is at least
+// PressureInterval old and reports pressure if the cache is full and the
+// window's miss rate is above PressureThreshold.
+func (lru *LRU) checkPressure() {
+	t := now()
+	if t.Sub(lru.windowStart) < lru.PressureInterval {
+		return
+	}
+
+	missRate := float64(lru.windowMisses) / float64(lru.windowHits+lru.windowMisses)
+	lru.windowStart, lru.windowHits, lru.windowMisses = t, 0, 0
+
+	if (lru.MaxEntries > 0) && (lru.ll.Len() >= lru.MaxEntries) && (missRate > lru.PressureThreshold) {
+		lru.OnPressure(float64(lru.ll.Len())/float64(lru.MaxEntries), missRate)
+	}
+}
+
+// victim returns the least recently used entry that is not pinned,
+// protected by MinResidency, or vetoed by CanEvict, or nil if there is
+// none.
+func (lru *LRU) victim() *list.Element {
+	var t time.Time
+	if lru.MinResidency != nil {
+		t = now()
+	}
+
+	for e := lru.ll.Back(); e != nil; e = e.Prev() {
+		kv := e.Value.(*lruEntry)
+		if lru.refs[kv.K] != 0 {
+			continue
+		}
+		if (lru.MinResidency != nil) && t.Before(lru.MinResidency(kv.V)) {
+			continue
+		}
+		if (lru.CanEvict != nil) && !lru.CanEvict(kv.K, kv.V) {
+			continue
+		}
+		return e
+	}
+	return nil
+}
+
+// evict removes an entry and reports it to OnEvicted.
+func (lru *LRU) evict(e *list.Element) {
+	lru.removeElement(e)
+
+	kv := e.Value.(*lruEntry)
+	if lru.Spill != nil {
+		lru.Spill.Store(kv.K, kv.V)
+	}
+	lru.reportEvicted(kv.K, kv.V)
+}
+
+// reportEvicted records an eviction and hands it to the callbacks.
+func (lru *LRU) reportEvicted(k cm.Key, v cm.Value) {
+	lru.evictions += 1
+	lru.logEviction(k)
+	if lru.OnEvicted != nil {
+		lru.OnEvicted(k, v)
+	}
+	if (lru.OnEvictedSampled != nil) && sample(lru.Rand, lru.SampleRate) {
+		lru.OnEvictedSampled(k, v)
+	}
+
+	if lru.OnEvictedBatch != nil {
+		lru.pending = append(lru.pending, cm.Entry{K: k, V: v})
+		if (lru.BatchSize > 0) && (len(lru.pending) >= lru.BatchSize) {
+			lru.FlushEvictions()
+		}
+	}
+}
+
+// sample reports true with probability rate, drawing from r or, if nil,
+// the default math/rand source.
+func sample(r *rand.Rand, rate float64) bool {
+	if r != nil {
+		return r.Float64() < rate
+	}
+	return rand.Float64() < rate
+}
+
+// SetOnEvicted replaces the OnEvicted callback. Every eviction is reported
+// to exactly one callback, the one set when the eviction happens. LRU is
+// not safe for concurrent use, so a caller swapping the callback while
+// other goroutines use the cache must hold the same lock that guards those
+// uses.
+func (lru *LRU) SetOnEvicted(fn func(k cm.Key, v cm.Value)) {
+	lru.OnEvicted = fn
+}
+
+// FlushEvictions delivers any evicted entries still waiting for a full
+// batch to OnEvictedBatch.
+func (lru *LRU) FlushEvictions() {
+	if len(lru.pending) == 0 {
+		return
+	}
+
+	batch := lru.pending
+	lru.pending = nil
+	if lru.OnEvictedBatch != nil {
+		lru.OnEvictedBatch(batch)
+	}
+}
+
+func (lru *LRU) removeElement(e *list.Element) {
+	k := e.Value.(*lruEntry).K
+	lru.ll.Remove(e)
+	delete(lru.cache, k)
+	lru.forget(k)
+}
+
+// forget drops the per-key state of a key that has left the cache and
+// fires its watchers.
+func (lru *LRU) forget(k cm.Key) {
+	delete(lru.refs, k)
+	delete(lru.meta, k)
+
+	if chs, ok := lru.watchers[k]; ok {
+		for _, ch := range chs {
+			close(ch)
+		}
+		delete(lru.watchers, k)
+	}
 }
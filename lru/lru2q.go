@@ -28,29 +28,85 @@ type LRU2Q struct {
 	// executed when an entry is purged from the cache.
 	OnEvicted func(k cm.Key, v cm.Value)
 
+	// OnClear optionally specifies a callback function to be executed
+	// once a Clear has completed, after any OnEvicted calls.
+	OnClear func()
+
+	// A key lives in at most one of the two queues: every path that moves
+	// an entry from fifo/qcount into ll/cache deletes it from the former
+	// first, and new keys only enter fifo after cache has been checked.
 	ll     *list.List
 	fifo   *list.List
 	cache  map[cm.Key]*list.Element
 	qcount map[cm.Key]*list.Element
+
+	// In adaptive mode the two queues share MaxEntries, with p entries
+	// targeted at the FIFO queue, and the ghost queues remember the keys
+	// recently evicted from each; see NewAdaptiveLRU2Q.
+	adaptive  bool
+	p         int
+	ghostFifo *ghostQueue
+	ghostLRU  *ghostQueue
 }
 
-// New creates a new Cache. maxEntries must be larger than zero.
+// New creates a new Cache.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
 func NewLRU2Q(maxEntries int) *LRU2Q {
-	if maxEntries <= 0 {
-		panic("maxEntries must be larger than 0!")
+	return NewLRU2QWithCapacity(maxEntries, 0)
+}
+
+// NewLRU2QWithCapacity creates a new Cache whose internal maps are pre-sized
+// to hold initialCap entries, avoiding repeated rehashing while they fill.
+func NewLRU2QWithCapacity(maxEntries, initialCap int) *LRU2Q {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
 	}
 
 	return &LRU2Q{
 		MaxEntries: maxEntries,
 		ll:         list.New(),
 		fifo:       list.New(),
-		cache:      make(map[cm.Key]*list.Element),
-		qcount:     make(map[cm.Key]*list.Element),
+		cache:      make(map[cm.Key]*list.Element, initialCap),
+		qcount:     make(map[cm.Key]*list.Element, initialCap),
+	}
+}
+
+// NewAdaptiveLRU2Q creates a new Cache whose two queues share maxEntries
+// entries and balance themselves, as ARC does, instead of holding up to
+// maxEntries each. Keys evicted from either queue are remembered in a ghost
+// queue of the same size; re-adding a key evicted from the FIFO queue
+// shows that recency pays off and shifts capacity towards the FIFO queue,
+// while re-adding one evicted from the LRU queue shifts it back. A re-added
+// ghost key goes straight to the LRU queue. maxEntries must be larger
+// than 0.
+func NewAdaptiveLRU2Q(maxEntries int) *LRU2Q {
+	if maxEntries <= 0 {
+		panic("maxEntries must be larger than 0!")
 	}
+
+	lru2q := NewLRU2Q(maxEntries)
+	lru2q.adaptive = true
+	lru2q.p = maxEntries / 2
+	lru2q.ghostFifo = newGhostQueue()
+	lru2q.ghostLRU = newGhostQueue()
+	return lru2q
+}
+
+// Balance returns the share of MaxEntries currently targeted at the FIFO
+// queue, from 0 to 1. It only moves for a cache created by
+// NewAdaptiveLRU2Q; otherwise each queue has a fixed MaxEntries and it
+// is always 0.5.
+func (lru2q *LRU2Q) Balance() float64 {
+	if !lru2q.adaptive {
+		return 0.5
+	}
+	return float64(lru2q.p) / float64(lru2q.MaxEntries)
 }
 
 // Add adds a value to the cache.
 func (lru2q *LRU2Q) Add(k cm.Key, v cm.Value) {
+	strictCheck("LRU2Q", lru2q.cache != nil && lru2q.qcount != nil, lru2q.MaxEntries >= 0)
+
 	if lru2q.cache == nil {
 		// `make` may fail
 		lru2q.cache = make(map[cm.Key]*list.Element)
@@ -71,27 +127,27 @@ func (lru2q *LRU2Q) Add(k cm.Key, v cm.Value) {
 
 	// key exists in FIFO
 	if ee, ok := lru2q.qcount[k]; ok {
-
 		kv := ee.Value.(*cm.Entry)
+		kv.V = v
 
 		// delete the element in FIFO
 		lru2q.fifo.Remove(ee)
 		delete(lru2q.qcount, k)
 
 		// add the element into LRU
-		if lru2q.ll.Len() == lru2q.MaxEntries {
-			b := lru2q.ll.Back()
-			k := b.Value.(*cm.Entry).K
-			lru2q.ll.Remove(b)
-			delete(lru2q.cache, k)
-		}
+		lru2q.makeRoomLRU()
 		lru2q.cache[k] = lru2q.ll.PushFront(kv)
 
 		return
 	}
 
+	if lru2q.adaptive {
+		lru2q.addAdaptive(k, v)
+		return
+	}
+
 	// add key into FIFO
-	if lru2q.fifo.Len() == lru2q.MaxEntries {
+	if (lru2q.MaxEntries > 0) && (lru2q.fifo.Len() == lru2q.MaxEntries) {
 		b := lru2q.fifo.Back()
 		k := b.Value.(*cm.Entry).K
 		lru2q.fifo.Remove(b)
@@ -100,13 +156,107 @@ func (lru2q *LRU2Q) Add(k cm.Key, v cm.Value) {
 	lru2q.qcount[k] = lru2q.fifo.PushFront(&cm.Entry{K: k, V: v})
 }
 
+// addAdaptive adds a key that is in neither queue to an adaptive cache,
+// adjusting the balance if the key is in a ghost queue.
+func (lru2q *LRU2Q) addAdaptive(k cm.Key, v cm.Value) {
+	if lru2q.ghostFifo == nil {
+		lru2q.ghostFifo = newGhostQueue()
+		lru2q.ghostLRU = newGhostQueue()
+	}
+
+	// shift the target by the ratio of the ghost queues, as ARC does, so
+	// that a hit in the smaller ghost queue weighs more
+	nf, nl := lru2q.ghostFifo.len(), lru2q.ghostLRU.len()
+	switch {
+	case lru2q.ghostFifo.remove(k):
+		delta := 1
+		if nl > nf {
+			delta = nl / nf
+		}
+		if lru2q.p += delta; lru2q.p > lru2q.MaxEntries {
+			lru2q.p = lru2q.MaxEntries
+		}
+	case lru2q.ghostLRU.remove(k):
+		delta := 1
+		if nf > nl {
+			delta = nf / nl
+		}
+		if lru2q.p -= delta; lru2q.p < 0 {
+			lru2q.p = 0
+		}
+	default:
+		if lru2q.Len() >= lru2q.MaxEntries {
+			lru2q.replace()
+		}
+		lru2q.qcount[k] = lru2q.fifo.PushFront(&cm.Entry{K: k, V: v})
+		return
+	}
+
+	if lru2q.Len() >= lru2q.MaxEntries {
+		lru2q.replace()
+	}
+	lru2q.cache[k] = lru2q.ll.PushFront(&cm.Entry{K: k, V: v})
+}
+
+// replace evicts one entry from an adaptive cache: from the FIFO queue if
+// it is over its target or the LRU queue is empty, otherwise from the LRU
+// queue. The key is remembered in the matching ghost queue and the entry
+// is reported to OnEvicted.
+func (lru2q *LRU2Q) replace() {
+	if (lru2q.fifo.Len() > 0) && (lru2q.fifo.Len() > lru2q.p || lru2q.ll.Len() == 0) {
+		b := lru2q.fifo.Back()
+		kv := b.Value.(*cm.Entry)
+		lru2q.fifo.Remove(b)
+		delete(lru2q.qcount, kv.K)
+		lru2q.ghostFifo.push(kv.K, lru2q.MaxEntries)
+		if lru2q.OnEvicted != nil {
+			lru2q.OnEvicted(kv.K, kv.V)
+		}
+	} else if lru2q.ll.Len() > 0 {
+		b := lru2q.ll.Back()
+		kv := b.Value.(*cm.Entry)
+		lru2q.ll.Remove(b)
+		delete(lru2q.cache, kv.K)
+		lru2q.ghostLRU.push(kv.K, lru2q.MaxEntries)
+		if lru2q.OnEvicted != nil {
+			lru2q.OnEvicted(kv.K, kv.V)
+		}
+	}
+}
+
+// makeRoomLRU evicts the back of a full LRU queue before an entry moves
+// into it from the FIFO queue. In adaptive mode the move does not change
+// the number of entries, so nothing is evicted.
+func (lru2q *LRU2Q) makeRoomLRU() {
+	if lru2q.adaptive {
+		return
+	}
+
+	if (lru2q.MaxEntries > 0) && (lru2q.ll.Len() == lru2q.MaxEntries) {
+		b := lru2q.ll.Back()
+		k := b.Value.(*cm.Entry).K
+		lru2q.ll.Remove(b)
+		delete(lru2q.cache, k)
+	}
+}
+
 // Get looks up a key's value from the cache.
 func (lru2q *LRU2Q) Get(k cm.Key) (v cm.Value, ok bool) {
+	v, _, ok = lru2q.GetWithQueue(k)
+	return v, ok
+}
+
+// GetWithQueue looks up a key's value from the cache like Get, and also
+// reports which queue served the hit: "fifo" for a first access, which
+// promotes the entry to the LRU queue, "lru" for a repeat access, or ""
+// on a miss.
+func (lru2q *LRU2Q) GetWithQueue(k cm.Key) (v cm.Value, queue string, ok bool) {
+	strictCheck("LRU2Q", lru2q.cache != nil && lru2q.qcount != nil, lru2q.MaxEntries >= 0)
 
 	if lru2q.cache != nil {
 		if ee, hit := lru2q.cache[k]; hit {
 			lru2q.ll.MoveToFront(ee)
-			return ee.Value.(*cm.Entry).V, true
+			return ee.Value.(*cm.Entry).V, "lru", true
 		}
 	}
 
@@ -123,22 +273,45 @@ func (lru2q *LRU2Q) Get(k cm.Key) (v cm.Value, ok bool) {
 			}
 
 			// add the element into LRU
-			if lru2q.ll.Len() == lru2q.MaxEntries {
-				b := lru2q.ll.Back()
-				k := b.Value.(*cm.Entry).K
-				lru2q.ll.Remove(b)
-				delete(lru2q.cache, k)
-			}
+			lru2q.makeRoomLRU()
 			kv := ee.Value.(*cm.Entry)
 			lru2q.cache[k] = lru2q.ll.PushFront(kv)
 
-			return kv.V, true
+			return kv.V, "fifo", true
 		}
 	}
 
+	return nil, "", false
+}
+
+// Peek looks up a key's value from either queue without moving it.
+func (lru2q *LRU2Q) Peek(k cm.Key) (v cm.Value, ok bool) {
+	if ee, hit := lru2q.cache[k]; hit {
+		return ee.Value.(*cm.Entry).V, true
+	}
+	if ee, hit := lru2q.qcount[k]; hit {
+		return ee.Value.(*cm.Entry).V, true
+	}
 	return nil, false
 }
 
+// Keys returns all keys, those of the LRU queue from the most to the least
+// recently used first, then those of the FIFO queue from the newest.
+func (lru2q *LRU2Q) Keys() []cm.Key {
+	keys := make([]cm.Key, 0, lru2q.Len())
+	if lru2q.cache != nil {
+		for e := lru2q.ll.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*cm.Entry).K)
+		}
+	}
+	if lru2q.qcount != nil {
+		for e := lru2q.fifo.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*cm.Entry).K)
+		}
+	}
+	return keys
+}
+
 // Remove removes the provided key from the cache.
 func (lru2q *LRU2Q) Remove(k cm.Key) {
 	if lru2q.cache != nil {
@@ -171,6 +344,19 @@ func (lru2q *LRU2Q) Len() int {
 	return n
 }
 
+// QueueLens returns the number of entries in each queue, keyed "fifo" and
+// "lru".
+func (lru2q *LRU2Q) QueueLens() map[string]int {
+	lens := map[string]int{"fifo": 0, "lru": 0}
+	if lru2q.qcount != nil {
+		lens["fifo"] = lru2q.fifo.Len()
+	}
+	if lru2q.cache != nil {
+		lens["lru"] = lru2q.ll.Len()
+	}
+	return lens
+}
+
 // Remove removes the provided key from the cache.
 func (lru2q *LRU2Q) Clear() {
 	if lru2q.OnEvicted != nil {
@@ -189,4 +375,43 @@ func (lru2q *LRU2Q) Clear() {
 	lru2q.qcount = nil
 	lru2q.fifo = nil
 	lru2q.cache = nil
+	lru2q.ghostFifo = nil
+	lru2q.ghostLRU = nil
+
+	if lru2q.OnClear != nil {
+		lru2q.OnClear()
+	}
+}
+
+// ghostQueue remembers the keys, but not the values, of recently evicted
+// entries, forgetting the oldest beyond a bound.
+type ghostQueue struct {
+	ll   *list.List
+	keys map[cm.Key]*list.Element
+}
+
+func newGhostQueue() *ghostQueue {
+	return &ghostQueue{ll: list.New(), keys: make(map[cm.Key]*list.Element)}
+}
+
+func (g *ghostQueue) push(k cm.Key, max int) {
+	g.keys[k] = g.ll.PushFront(k)
+	for g.ll.Len() > max {
+		b := g.ll.Back()
+		g.ll.Remove(b)
+		delete(g.keys, b.Value)
+	}
+}
+
+func (g *ghostQueue) remove(k cm.Key) bool {
+	e, ok := g.keys[k]
+	if ok {
+		g.ll.Remove(e)
+		delete(g.keys, k)
+	}
+	return ok
+}
+
+func (g *ghostQueue) len() int {
+	return g.ll.Len()
 }
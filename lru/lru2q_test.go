@@ -1,9 +1,11 @@
 package lru_test
 
 import (
+	"reflect"
 	"testing"
 
 	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
 )
 
 func TestLRU2QGet(t *testing.T) {
@@ -60,3 +62,169 @@ func TestLRU2QRemove(t *testing.T) {
 		t.Fatal("TestLRUKRemove returned a removed entry")
 	}
 }
+
+func TestLRU2QWithCapacity(t *testing.T) {
+	lru2q := lru.NewLRU2QWithCapacity(2, 16)
+	lru2q.Add("myKey", 1234)
+	if val, ok := lru2q.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestLRU2QWithCapacity failed.  Expected %d, got %v", 1234, val)
+	}
+	if n := lru2q.Len(); n != 1 {
+		t.Fatalf("TestLRU2QWithCapacity failed.  Expected len %d, got %d", 1, n)
+	}
+}
+
+func TestLRU2QOnClear(t *testing.T) {
+	var events []string
+	lru2q := lru.NewLRU2Q(4)
+	lru2q.OnEvicted = func(k cm.Key, v cm.Value) { events = append(events, "evicted") }
+	lru2q.OnClear = func() { events = append(events, "cleared") }
+
+	lru2q.Add("fifo", 1)
+	lru2q.Add("lru", 2)
+	lru2q.Get("lru")
+	lru2q.Clear()
+	if len(events) != 3 || events[2] != "cleared" {
+		t.Fatalf("TestLRU2QOnClear failed.  Expected [evicted evicted cleared], got %v", events)
+	}
+}
+
+func TestLRU2QClearOncePerKey(t *testing.T) {
+	evicted := make(map[interface{}]int)
+	lru2q := lru.NewLRU2Q(4)
+	lru2q.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted[k] += 1
+	}
+
+	// promoted by Get, then re-added
+	lru2q.Add("get", 1)
+	lru2q.Get("get")
+	lru2q.Add("get", 2)
+
+	// promoted by a second Add, then read
+	lru2q.Add("add", 1)
+	lru2q.Add("add", 2)
+	lru2q.Get("add")
+
+	// still only in the FIFO
+	lru2q.Add("fifo", 1)
+
+	if val, ok := lru2q.Get("add"); !ok || val != 2 {
+		t.Fatalf("TestLRU2QClearOncePerKey failed.  Expected %d, got %v", 2, val)
+	}
+	if n := lru2q.Len(); n != 3 {
+		t.Fatalf("TestLRU2QClearOncePerKey failed.  Expected len %d, got %d", 3, n)
+	}
+
+	lru2q.Clear()
+	if len(evicted) != 3 {
+		t.Fatalf("TestLRU2QClearOncePerKey failed.  Expected 3 evicted keys, got %v", evicted)
+	}
+	for k, n := range evicted {
+		if n != 1 {
+			t.Fatalf("TestLRU2QClearOncePerKey evicted %v %d times", k, n)
+		}
+	}
+}
+
+func TestLRU2QGetWithQueue(t *testing.T) {
+	lru2q := lru.NewLRU2Q(4)
+	lru2q.Add("myKey", 1234)
+
+	queueTests := []struct {
+		name          string
+		key           interface{}
+		expectedQueue string
+		expectedOk    bool
+	}{
+		{"first_access", "myKey", "fifo", true},
+		{"repeat_access", "myKey", "lru", true},
+		{"miss", "nonsense", "", false},
+	}
+	for _, tt := range queueTests {
+		val, queue, ok := lru2q.GetWithQueue(tt.key)
+		if ok != tt.expectedOk || queue != tt.expectedQueue {
+			t.Fatalf("%s: GetWithQueue = (%q, %v); want (%q, %v)", tt.name, queue, ok, tt.expectedQueue, tt.expectedOk)
+		} else if ok && val != 1234 {
+			t.Fatalf("%s expected get to return 1234 but got %v", tt.name, val)
+		}
+	}
+}
+
+func TestLRU2QQueueLens(t *testing.T) {
+	lru2q := lru.NewLRU2Q(0)
+	lru2q.Add("a", 1)
+	lru2q.Add("b", 2)
+	lru2q.Add("c", 3)
+	lru2q.Get("a")
+	lru2q.Add("b", 2)
+
+	lens := lru2q.QueueLens()
+	if lens["fifo"] != 1 || lens["lru"] != 2 || len(lens) != 2 {
+		t.Fatalf("TestLRU2QQueueLens failed.  Expected map[fifo:1 lru:2], got %v", lens)
+	}
+}
+
+func TestAdaptiveLRU2QOnEvicted(t *testing.T) {
+	var evicted []cm.Key
+	c := lru.NewAdaptiveLRU2Q(2)
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		if k != v {
+			t.Fatalf("TestAdaptiveLRU2QOnEvicted failed.  Expected %v, got %v", k, v)
+		}
+		evicted = append(evicted, k)
+	}
+
+	// b moves to the LRU queue; with the FIFO queue at its target of one
+	// entry c replaces b, after which d and e replace from the FIFO queue
+	for _, k := range []string{"a", "b", "b", "c", "d", "e"} {
+		c.Add(k, k)
+	}
+
+	if !reflect.DeepEqual(evicted, []cm.Key{"b", "a", "c"}) {
+		t.Fatalf("TestAdaptiveLRU2QOnEvicted failed.  Expected [b a c], got %v", evicted)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("TestAdaptiveLRU2QOnEvicted failed.  Expected %d, got %d", 2, c.Len())
+	}
+}
+
+func TestAdaptiveLRU2QBalance(t *testing.T) {
+	// every new key is added once more shortly after it was evicted from
+	// the FIFO queue, and never again, so recency pays off
+	recency := lru.NewAdaptiveLRU2Q(4)
+	for i := 0; i < 30; i++ {
+		recency.Add(i, i)
+		if i >= 5 {
+			recency.Add(i-5, i-5)
+		}
+		if recency.Len() > 4 {
+			t.Fatalf("TestAdaptiveLRU2QBalance failed.  Expected at most %d entries, got %d", 4, recency.Len())
+		}
+	}
+	if b := recency.Balance(); b <= 0.5 {
+		t.Fatalf("TestAdaptiveLRU2QBalance failed.  Expected the balance to move towards FIFO, got %v", b)
+	}
+	if err := recency.CheckInvariants(); err != nil {
+		t.Fatalf("TestAdaptiveLRU2QBalance failed: %v", err)
+	}
+
+	// hot keys pushed out of the LRU queue by a scan come back, so
+	// frequency pays off
+	frequency := lru.NewAdaptiveLRU2Q(4)
+	for _, k := range []string{"h1", "h1", "h2", "h2", "h3", "h3", "h4", "h4", "s1", "h1", "h2"} {
+		frequency.Add(k, k)
+	}
+	if b := frequency.Balance(); b >= 0.5 {
+		t.Fatalf("TestAdaptiveLRU2QBalance failed.  Expected the balance to move towards LRU, got %v", b)
+	}
+	for _, k := range []string{"h1", "h2"} {
+		if _, queue, ok := frequency.GetWithQueue(k); !ok || queue != "lru" {
+			t.Fatalf("TestAdaptiveLRU2QBalance failed.  Expected %q in the lru queue, got %q", k, queue)
+		}
+	}
+
+	if b := lru.NewLRU2Q(4).Balance(); b != 0.5 {
+		t.Fatalf("TestAdaptiveLRU2QBalance failed.  Expected a fixed balance of 0.5, got %v", b)
+	}
+}
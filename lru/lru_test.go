@@ -1,9 +1,16 @@
 package lru_test
 
 import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
 )
 
 func TestLRUGet(t *testing.T) {
@@ -56,3 +63,1499 @@ func TestLRURemove(t *testing.T) {
 		t.Fatal("TestLRURemove returned a removed entry")
 	}
 }
+
+func TestLRUWithCapacity(t *testing.T) {
+	lru := lru.NewLRUWithCapacity(2, 16)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("TestLRUWithCapacity failed.  Expected len %d, got %d", 2, n)
+	}
+	if _, ok := lru.Get("a"); ok {
+		t.Fatal("TestLRUWithCapacity returned an evicted entry")
+	}
+	if val, ok := lru.Get("c"); !ok || val != 3 {
+		t.Fatalf("TestLRUWithCapacity failed.  Expected %d, got %v", 3, val)
+	}
+}
+
+const benchEntries = 10000
+
+func BenchmarkLRUAdd(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lru := lru.NewLRU(benchEntries)
+		for k := 0; k < benchEntries; k++ {
+			lru.Add(k, k)
+		}
+	}
+}
+
+func BenchmarkLRUAddWithCapacity(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lru := lru.NewLRUWithCapacity(benchEntries, benchEntries)
+		for k := 0; k < benchEntries; k++ {
+			lru.Add(k, k)
+		}
+	}
+}
+
+func BenchmarkLRUKeys(b *testing.B) {
+	// if Keys touched value memory, large values would slow it down
+	values := []struct {
+		name string
+		new  func(k int) cm.Value
+	}{
+		{"int", func(k int) cm.Value { return k }},
+		{"1KiB", func(int) cm.Value { return make([]byte, 1024) }},
+	}
+
+	for _, tt := range values {
+		b.Run(tt.name, func(b *testing.B) {
+			c := lru.NewLRU(benchEntries)
+			for k := 0; k < benchEntries; k++ {
+				c.Add(k, tt.new(k))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Keys()
+			}
+		})
+	}
+}
+
+func TestLRUAcquire(t *testing.T) {
+	lru := lru.NewLRU(2)
+	lru.Add("pinned", 1)
+	if val, ok := lru.Acquire("pinned"); !ok || val != 1 {
+		t.Fatalf("TestLRUAcquire failed.  Expected %d, got %v", 1, val)
+	}
+	if _, ok := lru.Acquire("nonsense"); ok {
+		t.Fatal("TestLRUAcquire pinned a missing entry")
+	}
+
+	lru.Add("a", 2)
+	lru.Get("a")
+	lru.Add("b", 3)
+	if _, ok := lru.Get("pinned"); !ok {
+		t.Fatal("TestLRUAcquire evicted a pinned entry")
+	}
+	if _, ok := lru.Get("a"); ok {
+		t.Fatal("TestLRUAcquire kept an unpinned entry over capacity")
+	}
+
+	lru.Release("pinned")
+	lru.Get("b")
+	lru.Add("c", 4)
+	if _, ok := lru.Get("pinned"); ok {
+		t.Fatal("TestLRUAcquire kept a released entry over capacity")
+	}
+}
+
+func TestLRUAcquireAllPinned(t *testing.T) {
+	lru := lru.NewLRU(2)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Acquire("a")
+	lru.Acquire("b")
+	lru.Acquire("b")
+
+	lru.Add("c", 3)
+	if n := lru.Len(); n != 3 {
+		t.Fatalf("TestLRUAcquireAllPinned failed.  Expected len %d, got %d", 3, n)
+	}
+
+	lru.Release("b")
+	lru.Add("d", 4)
+	if _, ok := lru.Get("b"); !ok {
+		t.Fatal("TestLRUAcquireAllPinned evicted an entry that was still pinned")
+	}
+
+	lru.Release("a")
+	lru.Release("b")
+	lru.Add("e", 5)
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("TestLRUAcquireAllPinned failed.  Expected len %d, got %d", 2, n)
+	}
+}
+
+func TestLRUDemote(t *testing.T) {
+	lru := lru.NewLRU(3)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+
+	if !lru.Demote("c") {
+		t.Fatal("TestLRUDemote failed to demote a cached entry")
+	}
+	if lru.Demote("nonsense") {
+		t.Fatal("TestLRUDemote demoted a missing entry")
+	}
+
+	lru.Add("d", 4)
+	if _, ok := lru.Get("c"); ok {
+		t.Fatal("TestLRUDemote did not evict the demoted entry first")
+	}
+	if _, ok := lru.Get("a"); !ok {
+		t.Fatal("TestLRUDemote evicted the wrong entry")
+	}
+}
+
+func TestLRUWatch(t *testing.T) {
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	lru := lru.NewLRU(2)
+	if !closed(lru.Watch("nonsense")) {
+		t.Fatal("TestLRUWatch returned an open channel for a missing key")
+	}
+
+	lru.Add("removed", 1)
+	lru.Add("evicted", 2)
+	removed1, removed2 := lru.Watch("removed"), lru.Watch("removed")
+	evicted := lru.Watch("evicted")
+
+	lru.Add("removed", 3)
+	if closed(removed1) {
+		t.Fatal("TestLRUWatch fired on a value update")
+	}
+
+	lru.Remove("removed")
+	if !closed(removed1) || !closed(removed2) {
+		t.Fatal("TestLRUWatch did not fire every watcher on Remove")
+	}
+
+	lru.Add("a", 4)
+	if closed(evicted) {
+		t.Fatal("TestLRUWatch fired while the key was still cached")
+	}
+	lru.Add("b", 5)
+	if !closed(evicted) {
+		t.Fatal("TestLRUWatch did not fire on eviction")
+	}
+
+	cleared := lru.Watch("b")
+	lru.Clear()
+	if !closed(cleared) {
+		t.Fatal("TestLRUWatch did not fire on Clear")
+	}
+}
+
+func TestLRUEvictBatch(t *testing.T) {
+	var evicted []interface{}
+	lru := lru.NewLRU(4)
+	lru.EvictBatch = 3
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	for i := 0; i < 4; i++ {
+		lru.Add(i, i)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("TestLRUEvictBatch evicted %v before the cache was full", evicted)
+	}
+
+	lru.Add(4, 4)
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("TestLRUEvictBatch failed.  Expected len %d, got %d", 2, n)
+	}
+	if len(evicted) != 3 || evicted[0] != 0 || evicted[1] != 1 || evicted[2] != 2 {
+		t.Fatalf("TestLRUEvictBatch failed.  Expected evictions [0 1 2], got %v", evicted)
+	}
+
+	lru.Add(5, 5)
+	lru.Add(6, 6)
+	if len(evicted) != 3 {
+		t.Fatalf("TestLRUEvictBatch evicted %v before the cache was full again", evicted[3:])
+	}
+}
+
+func TestLRUEvictDefault(t *testing.T) {
+	var evicted []interface{}
+	lru := lru.NewLRU(2)
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("TestLRUEvictDefault failed.  Expected len %d, got %d", 2, n)
+	}
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("TestLRUEvictDefault failed.  Expected evictions [a], got %v", evicted)
+	}
+}
+
+func TestLRURemoveOldest(t *testing.T) {
+	var evicted []interface{}
+	lru := lru.NewLRU(0)
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	if _, _, ok := lru.RemoveOldest(); ok {
+		t.Fatal("TestLRURemoveOldest removed an entry from an empty cache")
+	}
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Get("a")
+	lru.Acquire("b")
+
+	k, v, ok := lru.RemoveOldest()
+	if !ok || k != "c" || v != 3 {
+		t.Fatalf("TestLRURemoveOldest failed.  Expected (c, 3), got (%v, %v)", k, v)
+	}
+	if len(evicted) != 1 || evicted[0] != "c" {
+		t.Fatalf("TestLRURemoveOldest failed.  Expected evictions [c], got %v", evicted)
+	}
+	if n := lru.Len(); n != 2 {
+		t.Fatalf("TestLRURemoveOldest failed.  Expected len %d, got %d", 2, n)
+	}
+}
+
+func TestLRUMustGet(t *testing.T) {
+	lru := lru.NewLRU(0)
+	lru.Add("myKey", 1234)
+	if val := lru.MustGet("myKey"); val != 1234 {
+		t.Fatalf("TestLRUMustGet failed.  Expected %d, got %v", 1234, val)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("TestLRUMustGet did not panic on a missing key")
+		} else if msg, _ := r.(string); !strings.Contains(msg, "nonsense") {
+			t.Fatalf("TestLRUMustGet panic %q does not name the key", msg)
+		}
+	}()
+	lru.MustGet("nonsense")
+}
+
+func TestLRUGetErr(t *testing.T) {
+	c := lru.NewLRU(0)
+	c.Add("myKey", 1234)
+	if val, err := c.GetErr("myKey"); err != nil || val != 1234 {
+		t.Fatalf("TestLRUGetErr failed.  Expected (%d, nil), got (%v, %v)", 1234, val, err)
+	}
+
+	val, err := c.GetErr("nonsense")
+	if !errors.Is(err, lru.ErrKeyNotFound) {
+		t.Fatalf("TestLRUGetErr failed.  Expected ErrKeyNotFound, got %v", err)
+	} else if val != nil {
+		t.Fatalf("TestLRUGetErr returned %v for a missing key", val)
+	}
+}
+
+func TestLRUNoReadPromote(t *testing.T) {
+	promoteTests := []struct {
+		name          string
+		zeroValue     bool
+		noReadPromote bool
+		expectedGone  interface{}
+	}{
+		{"promoting", false, false, "b"},
+		{"zero_value_promoting", true, false, "b"},
+		{"insertion_order", false, true, "a"},
+	}
+	for _, tt := range promoteTests {
+		c := lru.NewLRU(2)
+		if tt.zeroValue {
+			c = &lru.LRU{MaxEntries: 2}
+		}
+		c.NoReadPromote = tt.noReadPromote
+		c.Add("a", 1)
+		c.Add("b", 2)
+		c.Get("a")
+		c.Add("c", 3)
+		if _, ok := c.Get(tt.expectedGone); ok {
+			t.Fatalf("%s: expected %v to be evicted", tt.name, tt.expectedGone)
+		}
+		if n := c.Len(); n != 2 {
+			t.Fatalf("%s: expected len %d, got %d", tt.name, 2, n)
+		}
+	}
+}
+
+func TestLRUOnClear(t *testing.T) {
+	var events []string
+	lru := lru.NewLRU(0)
+	lru.OnEvicted = func(k cm.Key, v cm.Value) { events = append(events, "evicted") }
+	lru.OnClear = func() { events = append(events, "cleared") }
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Clear()
+	if len(events) != 3 || events[2] != "cleared" {
+		t.Fatalf("TestLRUOnClear failed.  Expected [evicted evicted cleared], got %v", events)
+	}
+}
+
+func TestLRUUpsert(t *testing.T) {
+	sum := func(old, new cm.Value) cm.Value { return old.(int) + new.(int) }
+
+	lru := lru.NewLRU(2)
+	if val := lru.Upsert("total", 1, sum); val != 1 {
+		t.Fatalf("TestLRUUpsert failed.  Expected %d, got %v", 1, val)
+	}
+	lru.Add("other", 0)
+	if val := lru.Upsert("total", 2, sum); val != 3 {
+		t.Fatalf("TestLRUUpsert failed.  Expected %d, got %v", 3, val)
+	}
+	if val := lru.Upsert("total", 4, sum); val != 7 {
+		t.Fatalf("TestLRUUpsert failed.  Expected %d, got %v", 7, val)
+	}
+
+	// the merged entry was promoted, so "other" is evicted first
+	lru.Add("new", 0)
+	if val, ok := lru.Get("total"); !ok || val != 7 {
+		t.Fatalf("TestLRUUpsert failed.  Expected %d, got %v", 7, val)
+	}
+	if _, ok := lru.Get("other"); ok {
+		t.Fatal("TestLRUUpsert did not promote the merged entry")
+	}
+}
+
+func TestLRUUpsertBookkeeping(t *testing.T) {
+	sum := func(old, new cm.Value) cm.Value { return old.(int) + new.(int) }
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	defer lru.SetNow(func() time.Time { return clock })()
+
+	c := lru.NewLRU(0).WithOpLog(4)
+	c.PromoteCooldown = time.Minute
+	c.Upsert("a", 1, sum)
+	c.Add("b", 2)
+
+	// the merge restarts the cooldown of a, so a read half a minute later
+	// does not move it
+	clock = start.Add(90 * time.Second)
+	c.Upsert("a", 1, sum)
+	c.Add("b", 2)
+	clock = start.Add(120 * time.Second)
+	c.Get("a")
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []cm.Key{"b", "a"}) {
+		t.Fatalf("TestLRUUpsertBookkeeping failed.  Expected [b a], got %v", keys)
+	}
+
+	var ops []string
+	for _, op := range c.RecentOps() {
+		ops = append(ops, op.Op+" "+op.Key.(string))
+	}
+	expected := []string{"add b", "add a", "add b", "get a"}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Fatalf("TestLRUUpsertBookkeeping failed.  Expected %v, got %v", expected, ops)
+	}
+}
+
+func TestLRUSampleKeys(t *testing.T) {
+	newLRU := func() *lru.LRU {
+		lru := lru.NewLRU(0)
+		lru.Rand = rand.New(rand.NewSource(42))
+		for i := 0; i < 100; i++ {
+			lru.Add(i, i)
+		}
+		return lru
+	}
+
+	first, second := newLRU().SampleKeys(10), newLRU().SampleKeys(10)
+	if len(first) != 10 {
+		t.Fatalf("TestLRUSampleKeys failed.  Expected %d keys, got %d", 10, len(first))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("TestLRUSampleKeys is not reproducible: %v vs %v", first, second)
+		}
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, k := range first {
+		if seen[k] {
+			t.Fatalf("TestLRUSampleKeys returned %v twice", k)
+		}
+		seen[k] = true
+	}
+
+	if keys := newLRU().SampleKeys(1000); len(keys) != 100 {
+		t.Fatalf("TestLRUSampleKeys failed.  Expected %d keys, got %d", 100, len(keys))
+	}
+}
+
+func TestLRUPeek(t *testing.T) {
+	lru := lru.NewLRU(2)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	if val, ok := lru.Peek("a"); !ok || val != 1 {
+		t.Fatalf("TestLRUPeek failed.  Expected %d, got %v", 1, val)
+	}
+	if _, ok := lru.Peek("nonsense"); ok {
+		t.Fatal("TestLRUPeek returned a missing entry")
+	}
+
+	lru.Add("c", 3)
+	if _, ok := lru.Peek("a"); ok {
+		t.Fatal("TestLRUPeek promoted the entry it read")
+	}
+}
+
+func TestMaxEntriesSemantics(t *testing.T) {
+	constructors := []struct {
+		name string
+		new  func(maxEntries int) cm.Cache
+	}{
+		{"lru", func(n int) cm.Cache { return lru.NewLRU(n) }},
+		{"lruk", func(n int) cm.Cache { return lru.NewLRUK(n, 1) }},
+		{"lru2q", func(n int) cm.Cache { return lru.NewLRU2Q(n) }},
+		{"gdsf", func(n int) cm.Cache { return lru.NewGDSFCache(n, nil) }},
+	}
+	for _, tt := range constructors {
+		c := tt.new(0)
+		for i := 0; i < 100; i++ {
+			c.Add(i, i)
+			c.Get(i)
+		}
+		if n := c.Len(); n != 100 {
+			t.Fatalf("%s: zero MaxEntries should be unlimited; len = %d, want %d", tt.name, n, 100)
+		}
+
+		if !panics(func() { tt.new(-1) }) {
+			t.Fatalf("%s: negative MaxEntries did not panic", tt.name)
+		}
+	}
+
+	if !panics(func() { lru.NewBytesLRU(-1) }) {
+		t.Fatal("bytes_lru: negative MaxEntries did not panic")
+	}
+}
+
+func TestLRUEvict(t *testing.T) {
+	evictTests := []struct {
+		name     string
+		count    int
+		n        int
+		expected int
+	}{
+		{"partial", 5, 3, 3},
+		{"all", 3, 3, 3},
+		{"more_than_cached", 2, 5, 2},
+		{"empty", 0, 1, 0},
+	}
+	for _, tt := range evictTests {
+		var evicted []interface{}
+		lru := lru.NewLRU(0)
+		lru.OnEvicted = func(k cm.Key, v cm.Value) {
+			evicted = append(evicted, k)
+		}
+		for i := 0; i < tt.count; i++ {
+			lru.Add(i, i)
+		}
+
+		if n := lru.Evict(tt.n); n != tt.expected {
+			t.Fatalf("%s: evicted %d; want %d", tt.name, n, tt.expected)
+		}
+		if len(evicted) != tt.expected {
+			t.Fatalf("%s: OnEvicted fired %d times; want %d", tt.name, len(evicted), tt.expected)
+		}
+		for i, k := range evicted {
+			if k != i {
+				t.Fatalf("%s: evicted %v out of order", tt.name, evicted)
+			}
+		}
+		if n := lru.Len(); n != tt.count-tt.expected {
+			t.Fatalf("%s: len = %d; want %d", tt.name, n, tt.count-tt.expected)
+		}
+	}
+}
+
+func TestLRUSortedKeys(t *testing.T) {
+	strs := lru.NewLRU(0)
+	for _, k := range []string{"b", "c", "a"} {
+		strs.Add(k, 0)
+	}
+	keys := strs.SortedKeys(func(a, b cm.Key) bool { return a.(string) < b.(string) })
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Fatalf("TestLRUSortedKeys failed.  Expected [a b c], got %v", keys)
+	}
+
+	ints := lru.NewLRU(0)
+	for _, k := range []int{2, 3, 1} {
+		ints.Add(k, 0)
+	}
+	keys = ints.SortedKeys(func(a, b cm.Key) bool { return a.(int) > b.(int) })
+	if len(keys) != 3 || keys[0] != 3 || keys[1] != 2 || keys[2] != 1 {
+		t.Fatalf("TestLRUSortedKeys failed.  Expected [3 2 1], got %v", keys)
+	}
+
+	// sorting must not promote: 2 is still the least recently used
+	ints.MaxEntries = 3
+	ints.Add(4, 0)
+	if _, ok := ints.Peek(2); ok {
+		t.Fatal("TestLRUSortedKeys changed the recency order")
+	}
+}
+
+func TestLRUKeys(t *testing.T) {
+	lru := lru.NewLRU(0)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Get("a")
+
+	keys := lru.Keys()
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "c" || keys[2] != "b" {
+		t.Fatalf("TestLRUKeys failed.  Expected [a c b], got %v", keys)
+	}
+}
+
+func TestLRUKeysMatchSnapshot(t *testing.T) {
+	lru := lru.NewLRU(50)
+	for i := 0; i < 100; i++ {
+		lru.Add(i, i*i)
+		if i%3 == 0 {
+			lru.Get(i / 2)
+		}
+		if i%7 == 0 {
+			lru.Remove(i - 1)
+		}
+	}
+
+	keys, snapshot := lru.Keys(), lru.Snapshot()
+	if len(keys) != len(snapshot) {
+		t.Fatalf("TestLRUKeysMatchSnapshot failed.  Expected %d keys, got %d", len(snapshot), len(keys))
+	}
+	for i, kv := range snapshot {
+		if keys[i] != kv.K {
+			t.Fatalf("TestLRUKeysMatchSnapshot failed at %d.  Expected %v, got %v", i, kv.K, keys[i])
+		}
+	}
+}
+
+func TestLRUClearAsync(t *testing.T) {
+	var mu sync.Mutex
+	evicted := make(map[interface{}]bool)
+	cleared := false
+
+	lru := lru.NewLRU(0)
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		mu.Lock()
+		evicted[k] = true
+		mu.Unlock()
+	}
+	lru.OnClear = func() {
+		mu.Lock()
+		cleared = true
+		mu.Unlock()
+	}
+	for i := 0; i < 100; i++ {
+		lru.Add(i, i)
+	}
+
+	done := lru.ClearAsync()
+	if n := lru.Len(); n != 0 {
+		t.Fatalf("TestLRUClearAsync failed.  Expected len %d, got %d", 0, n)
+	}
+	lru.Add("myKey", 1234)
+	if val, ok := lru.Get("myKey"); !ok || val != 1234 {
+		t.Fatal("TestLRUClearAsync left the cache unusable")
+	}
+
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(evicted) != 100 || !cleared {
+		t.Fatalf("TestLRUClearAsync failed.  Expected 100 evictions and a clear, got %d and %v", len(evicted), cleared)
+	}
+	if evicted["myKey"] {
+		t.Fatal("TestLRUClearAsync evicted an entry added after the clear")
+	}
+}
+
+func TestLRUTrimToSize(t *testing.T) {
+	trimTests := []struct {
+		name     string
+		target   int
+		expected int
+	}{
+		{"smaller", 2, 3},
+		{"same", 5, 0},
+		{"larger", 10, 0},
+		{"empty", 0, 5},
+	}
+	for _, tt := range trimTests {
+		evicted := 0
+		lru := lru.NewLRU(8)
+		lru.OnEvicted = func(k cm.Key, v cm.Value) {
+			evicted += 1
+		}
+		for i := 0; i < 5; i++ {
+			lru.Add(i, i)
+		}
+
+		if n := lru.TrimToSize(tt.target); n != tt.expected || evicted != tt.expected {
+			t.Fatalf("%s: trimmed %d with %d callbacks; want %d", tt.name, n, evicted, tt.expected)
+		}
+		if n := lru.Len(); n != 5-tt.expected {
+			t.Fatalf("%s: len = %d; want %d", tt.name, n, 5-tt.expected)
+		}
+		if lru.MaxEntries != 8 {
+			t.Fatalf("%s: MaxEntries changed to %d", tt.name, lru.MaxEntries)
+		}
+	}
+}
+
+func TestLRUMeta(t *testing.T) {
+	lru := lru.NewLRU(2)
+	if lru.SetMeta("a", "etag") {
+		t.Fatal("TestLRUMeta attached metadata to a missing entry")
+	}
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	if !lru.SetMeta("a", "etag") {
+		t.Fatal("TestLRUMeta failed to attach metadata")
+	}
+	if meta, ok := lru.GetMeta("a"); !ok || meta != "etag" {
+		t.Fatalf("TestLRUMeta failed.  Expected %q, got %v", "etag", meta)
+	}
+	if _, ok := lru.GetMeta("b"); ok {
+		t.Fatal("TestLRUMeta returned metadata that was never set")
+	}
+
+	// metadata access must not promote "a", so it is evicted next
+	lru.Add("c", 3)
+	if _, ok := lru.GetMeta("a"); ok {
+		t.Fatal("TestLRUMeta kept metadata for an evicted entry")
+	}
+
+	lru.Add("a", 1)
+	if _, ok := lru.GetMeta("a"); ok {
+		t.Fatal("TestLRUMeta resurrected metadata for a re-added entry")
+	}
+}
+
+func TestLRUOnPressure(t *testing.T) {
+	var rates []float64
+	lru := lru.NewLRU(2)
+	lru.PressureThreshold = 0.5
+	lru.OnPressure = func(fillRatio float64, recentMissRate float64) {
+		if fillRatio != 1 {
+			t.Fatalf("TestLRUOnPressure failed.  Expected fill ratio 1, got %v", fillRatio)
+		}
+		rates = append(rates, recentMissRate)
+	}
+
+	// misses while the cache is not full are not pressure
+	lru.Get("nonsense")
+	lru.Add("a", 1)
+	lru.Get("nonsense")
+	if len(rates) != 0 {
+		t.Fatalf("TestLRUOnPressure fired below capacity: %v", rates)
+	}
+
+	lru.Add("b", 2)
+	lru.Get("a")
+	lru.Get("nonsense")
+	if len(rates) != 0 {
+		t.Fatalf("TestLRUOnPressure fired at the threshold: %v", rates)
+	}
+
+	lru.Get("nonsense")
+	lru.Get("nonsense")
+	if len(rates) != 2 || rates[0] != 1 || rates[1] != 1 {
+		t.Fatalf("TestLRUOnPressure failed.  Expected rates [1 1], got %v", rates)
+	}
+}
+
+func TestLRUOnPressureThrottle(t *testing.T) {
+	clock := time.Unix(0, 0)
+	defer lru.SetNow(func() time.Time { return clock })()
+
+	fired := 0
+	lru := lru.NewLRU(1)
+	lru.PressureInterval = time.Minute
+	lru.OnPressure = func(fillRatio float64, recentMissRate float64) {
+		fired += 1
+	}
+	lru.Add("a", 1)
+
+	// the first lookup opens a window rather than closing one
+	clock = clock.Add(time.Minute)
+	for i := 0; i < 10; i++ {
+		lru.Get("nonsense")
+	}
+	if fired != 0 {
+		t.Fatalf("TestLRUOnPressureThrottle failed.  Expected %d calls, got %d", 0, fired)
+	}
+
+	clock = clock.Add(time.Minute)
+	lru.Get("nonsense")
+	if fired != 1 {
+		t.Fatalf("TestLRUOnPressureThrottle failed.  Expected %d call, got %d", 1, fired)
+	}
+
+	clock = clock.Add(30 * time.Second)
+	lru.Get("nonsense")
+	if fired != 1 {
+		t.Fatal("TestLRUOnPressureThrottle fired within the interval")
+	}
+
+	clock = clock.Add(30 * time.Second)
+	lru.Get("nonsense")
+	if fired != 2 {
+		t.Fatalf("TestLRUOnPressureThrottle failed.  Expected %d calls, got %d", 2, fired)
+	}
+}
+
+func TestLRUSnapshot(t *testing.T) {
+	var mu sync.Mutex
+	lru := lru.NewLRU(0)
+	for i := 0; i < 100; i++ {
+		lru.Add(i, i)
+	}
+
+	mu.Lock()
+	entries := lru.Snapshot()
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			lru.Remove(i)
+			lru.Add(i+100, i)
+			mu.Unlock()
+		}
+	}()
+
+	if len(entries) != 100 {
+		t.Fatalf("TestLRUSnapshot failed.  Expected %d entries, got %d", 100, len(entries))
+	}
+	for i, kv := range entries {
+		if kv.K != 99-i || kv.V != 99-i {
+			t.Fatalf("TestLRUSnapshot failed.  Expected entry %d at %d, got %v", 99-i, i, kv)
+		}
+	}
+	<-done
+
+	if n := lru.Len(); n != 100 {
+		t.Fatalf("TestLRUSnapshot failed.  Expected len %d, got %d", 100, n)
+	}
+}
+
+func TestLRURemoveVictim(t *testing.T) {
+	var evicted []interface{}
+	lru := lru.NewLRU(0)
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Get("a")
+
+	if k, v, ok := lru.RemoveVictim(); !ok || k != "b" || v != 2 {
+		t.Fatalf("TestLRURemoveVictim failed.  Expected (b, 2), got (%v, %v)", k, v)
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("TestLRURemoveVictim failed.  Expected evictions [b], got %v", evicted)
+	}
+}
+
+func TestLRUProbe(t *testing.T) {
+	probeTests := []struct {
+		name            string
+		maxEntries      int
+		count           int
+		keyToProbe      interface{}
+		expectedPresent bool
+		expectedFree    int
+	}{
+		{"empty_absent", 3, 0, 0, false, 3},
+		{"partial_present", 3, 2, 1, true, 1},
+		{"partial_absent", 3, 2, 5, false, 1},
+		{"full_present", 3, 3, 0, true, 0},
+		{"unlimited", 0, 2, 0, true, -1},
+	}
+	for _, tt := range probeTests {
+		lru := lru.NewLRU(tt.maxEntries)
+		for i := 0; i < tt.count; i++ {
+			lru.Add(i, i)
+		}
+		present, free := lru.Probe(tt.keyToProbe)
+		if present != tt.expectedPresent || free != tt.expectedFree {
+			t.Fatalf("%s: Probe = (%v, %d); want (%v, %d)", tt.name, present, free, tt.expectedPresent, tt.expectedFree)
+		}
+	}
+}
+
+func TestLRUClearRetain(t *testing.T) {
+	clears := []struct {
+		name  string
+		clear func(c *lru.LRU)
+	}{
+		{"clear", (*lru.LRU).Clear},
+		{"clear_retain", (*lru.LRU).ClearRetain},
+	}
+	for _, tt := range clears {
+		evicted := 0
+		lru := lru.NewLRU(2)
+		lru.OnEvicted = func(k cm.Key, v cm.Value) {
+			evicted += 1
+		}
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		tt.clear(lru)
+		if n := lru.Len(); n != 0 || evicted != 2 {
+			t.Fatalf("%s: len = %d, evicted = %d; want 0 and 2", tt.name, n, evicted)
+		}
+		if _, ok := lru.Get("a"); ok {
+			t.Fatalf("%s: returned a cleared entry", tt.name)
+		}
+
+		lru.Add("c", 3)
+		lru.Add("d", 4)
+		lru.Add("e", 5)
+		if val, ok := lru.Get("e"); !ok || val != 5 || lru.Len() != 2 {
+			t.Fatalf("%s: cache unusable after clearing", tt.name)
+		}
+	}
+}
+
+func benchmarkLRURefill(b *testing.B, clear func(c *lru.LRU)) {
+	lru := lru.NewLRU(0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for k := 0; k < benchEntries; k++ {
+			lru.Add(k, k)
+		}
+		clear(lru)
+	}
+}
+
+func BenchmarkLRUClearRefill(b *testing.B) {
+	benchmarkLRURefill(b, (*lru.LRU).Clear)
+}
+
+func BenchmarkLRUClearRetainRefill(b *testing.B) {
+	benchmarkLRURefill(b, (*lru.LRU).ClearRetain)
+}
+
+func TestLRUForEach(t *testing.T) {
+	lru := lru.NewLRU(0)
+	for i := 0; i < 10; i++ {
+		lru.Add(i, i)
+	}
+
+	var visited []interface{}
+	lru.ForEach(func(k cm.Key, v cm.Value) {
+		visited = append(visited, k)
+		if k.(int)%2 == 0 {
+			lru.Remove(k)
+			lru.Remove(k.(int) - 1)
+		}
+	})
+
+	if len(visited) != 10 {
+		t.Fatalf("TestLRUForEach failed.  Expected %d visits, got %v", 10, visited)
+	}
+	if keys := lru.Keys(); len(keys) != 1 || keys[0] != 9 {
+		t.Fatalf("TestLRUForEach failed.  Expected [9] to remain, got %v", keys)
+	}
+}
+
+func TestLRUZeroMeansEmpty(t *testing.T) {
+	var evicted []interface{}
+	lru := lru.NewLRU(0)
+	lru.ZeroMeansEmpty = true
+	lru.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	for i := 0; i < 3; i++ {
+		lru.Add(i, i)
+		if n := lru.Len(); n != 0 {
+			t.Fatalf("TestLRUZeroMeansEmpty failed.  Expected len %d, got %d", 0, n)
+		}
+	}
+	if _, ok := lru.Get(0); ok {
+		t.Fatal("TestLRUZeroMeansEmpty returned a rejected entry")
+	}
+	if len(evicted) != 3 {
+		t.Fatalf("TestLRUZeroMeansEmpty failed.  Expected 3 evictions, got %v", evicted)
+	}
+	if _, free := lru.Probe(0); free != 0 {
+		t.Fatalf("TestLRUZeroMeansEmpty failed.  Expected %d free, got %d", 0, free)
+	}
+
+	lru.MaxEntries = 1
+	lru.Add("myKey", 1234)
+	if val, ok := lru.Get("myKey"); !ok || val != 1234 {
+		t.Fatal("TestLRUZeroMeansEmpty rejected an entry with room for it")
+	}
+}
+
+func TestLRUIncrement(t *testing.T) {
+	incrementTests := []struct {
+		name          string
+		value         interface{}
+		delta         int64
+		expected      int64
+		expectedValue interface{}
+		expectedOk    bool
+	}{
+		{"int", 1, 2, 3, 3, true},
+		{"int64_negative", int64(10), -4, 6, int64(6), true},
+		{"uint8", uint8(1), 1, 2, uint8(2), true},
+		{"string", "one", 1, 0, "one", false},
+		{"float", 1.5, 1, 0, 1.5, false},
+	}
+	for _, tt := range incrementTests {
+		lru := lru.NewLRU(0)
+		lru.Add("counter", tt.value)
+		n, ok := lru.Increment("counter", tt.delta)
+		if ok != tt.expectedOk || n != tt.expected {
+			t.Fatalf("%s: Increment = (%d, %v); want (%d, %v)", tt.name, n, ok, tt.expected, tt.expectedOk)
+		}
+		if val, _ := lru.Get("counter"); val != tt.expectedValue {
+			t.Fatalf("%s: stored %v (%T); want %v (%T)", tt.name, val, val, tt.expectedValue, tt.expectedValue)
+		}
+	}
+
+	lru := lru.NewLRU(0)
+	if _, ok := lru.Increment("nonsense", 1); ok {
+		t.Fatal("TestLRUIncrement incremented a missing key")
+	}
+}
+
+func TestLRURestoreExact(t *testing.T) {
+	src := lru.NewLRU(4)
+	for i := 0; i < 6; i++ {
+		src.Add(i, i)
+	}
+	src.Get(3)
+	src.Get(5)
+
+	dst := lru.NewLRU(4)
+	dst.Add("stale", 0)
+	dst.RestoreExact(src.Snapshot())
+
+	want, got := src.Keys(), dst.Keys()
+	if len(got) != len(want) {
+		t.Fatalf("TestLRURestoreExact failed.  Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("TestLRURestoreExact failed.  Expected %v, got %v", want, got)
+		}
+	}
+
+	small := lru.NewLRU(2)
+	small.RestoreExact(src.Snapshot())
+	if got := small.Keys(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("TestLRURestoreExact failed.  Expected %v, got %v", want[:2], got)
+	}
+}
+
+func TestLRUOnEvictedBatch(t *testing.T) {
+	var batches [][]cm.Entry
+	lru := lru.NewLRU(1)
+	lru.BatchSize = 2
+	lru.OnEvictedBatch = func(entries []cm.Entry) {
+		batches = append(batches, entries)
+	}
+
+	for i := 0; i < 6; i++ {
+		lru.Add(i, i)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 2 {
+		t.Fatalf("TestLRUOnEvictedBatch failed.  Expected two batches of 2, got %v", batches)
+	}
+	if batches[0][0].K != 0 || batches[0][1].K != 1 || batches[1][0].K != 2 || batches[1][1].K != 3 {
+		t.Fatalf("TestLRUOnEvictedBatch delivered entries out of order: %v", batches)
+	}
+
+	lru.FlushEvictions()
+	if len(batches) != 3 || len(batches[2]) != 1 || batches[2][0].K != 4 {
+		t.Fatalf("TestLRUOnEvictedBatch failed.  Expected a partial batch [4], got %v", batches)
+	}
+	lru.FlushEvictions()
+	if len(batches) != 3 {
+		t.Fatal("TestLRUOnEvictedBatch flushed an empty batch")
+	}
+
+	lru.Add(6, 6)
+	lru.Clear()
+	if len(batches) != 4 || len(batches[3]) != 2 || batches[3][0].K != 5 || batches[3][1].K != 6 {
+		t.Fatalf("TestLRUOnEvictedBatch failed.  Expected a final batch [5 6] on Clear, got %v", batches)
+	}
+}
+
+func TestLRUOnSizeThreshold(t *testing.T) {
+	var sizes []int
+	lru := lru.NewLRU(0)
+	lru.Add("before", 0)
+	lru.OnSizeThreshold(3, func(size int) {
+		sizes = append(sizes, size)
+	})
+
+	for i := 0; i < 7; i++ {
+		lru.Add(i, i)
+	}
+	if len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 6 {
+		t.Fatalf("TestLRUOnSizeThreshold failed.  Expected [3 6], got %v", sizes)
+	}
+
+	// shrinking and growing past 6 again must not fire a second time
+	lru.Remove(0)
+	lru.Remove(1)
+	lru.Add(0, 0)
+	lru.Add(1, 1)
+	lru.Add(7, 7)
+	if len(sizes) != 3 || sizes[2] != 9 {
+		t.Fatalf("TestLRUOnSizeThreshold failed.  Expected [3 6 9], got %v", sizes)
+	}
+}
+
+func TestLRUSetOnEvicted(t *testing.T) {
+	var mu sync.Mutex
+	reported := make(map[interface{}]int)
+	callback := func(id int) func(k cm.Key, v cm.Value) {
+		return func(k cm.Key, v cm.Value) {
+			if v != id {
+				t.Errorf("TestLRUSetOnEvicted: callback %d saw an entry added under callback %v", id, v)
+			}
+			reported[k] += 1
+		}
+	}
+
+	current := 0
+	lru := lru.NewLRU(1)
+	lru.SetOnEvicted(callback(current))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			mu.Lock()
+			lru.Add(i, current)
+			mu.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			// evict the entry added under the old callback before swapping
+			lru.Evict(1)
+			current += 1
+			lru.SetOnEvicted(callback(current))
+			mu.Unlock()
+		}
+	}()
+	wg.Wait()
+
+	lru.Clear()
+	if len(reported) != 1000 {
+		t.Fatalf("TestLRUSetOnEvicted failed.  Expected 1000 keys reported, got %d", len(reported))
+	}
+	for k, n := range reported {
+		if n != 1 {
+			t.Fatalf("TestLRUSetOnEvicted reported %v %d times", k, n)
+		}
+	}
+}
+
+func TestLRUSwapContents(t *testing.T) {
+	var c *lru.LRU
+	var evicted []interface{}
+	c = lru.NewLRU(0)
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		if n := c.Len(); n != 2 {
+			t.Fatalf("TestLRUSwapContents observed len %d while reporting old entries", n)
+		}
+		evicted = append(evicted, k)
+	}
+	c.Add("old", 1)
+	c.Add("kept", 2)
+	gone, kept := c.Watch("old"), c.Watch("kept")
+
+	c.SwapContents([]cm.Entry{{K: "new", V: 3}, {K: "kept", V: 4}})
+
+	if keys := c.Keys(); len(keys) != 2 || keys[0] != "new" || keys[1] != "kept" {
+		t.Fatalf("TestLRUSwapContents failed.  Expected [new kept], got %v", keys)
+	}
+	if val, _ := c.Get("kept"); val != 4 {
+		t.Fatalf("TestLRUSwapContents failed.  Expected %d, got %v", 4, val)
+	}
+	if len(evicted) != 1 || evicted[0] != "old" {
+		t.Fatalf("TestLRUSwapContents failed.  Expected [old] reported, got %v", evicted)
+	}
+	if m := c.Collect(); m.Evictions != 1 {
+		t.Fatalf("TestLRUSwapContents failed.  Expected %d, got %d", 1, m.Evictions)
+	}
+
+	select {
+	case <-gone:
+	default:
+		t.Fatal("TestLRUSwapContents did not fire the watcher of a dropped key")
+	}
+	select {
+	case <-kept:
+		t.Fatal("TestLRUSwapContents fired the watcher of a kept key")
+	default:
+	}
+}
+
+func TestLRUEvictOlderThan(t *testing.T) {
+	clock := time.Unix(0, 0)
+	defer lru.SetNow(func() time.Time { return clock })()
+
+	var evicted []interface{}
+	c := lru.NewLRU(0)
+	c.NoReadPromote = true
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	c.Add("stale", 1)
+	c.Add("read", 2)
+	c.Add("pinned", 3)
+	c.Acquire("pinned")
+	clock = clock.Add(5 * time.Minute)
+	c.Get("read")
+	c.Add("fresh", 4)
+	clock = clock.Add(5 * time.Minute)
+
+	if n := c.EvictOlderThan(6 * time.Minute); n != 1 {
+		t.Fatalf("TestLRUEvictOlderThan failed.  Expected %d eviction, got %d", 1, n)
+	}
+	if len(evicted) != 1 || evicted[0] != "stale" {
+		t.Fatalf("TestLRUEvictOlderThan failed.  Expected evictions [stale], got %v", evicted)
+	}
+	if keys := c.Keys(); len(keys) != 3 {
+		t.Fatalf("TestLRUEvictOlderThan failed.  Expected 3 keys left, got %v", keys)
+	}
+
+	if n := c.EvictOlderThan(time.Minute); n != 2 {
+		t.Fatalf("TestLRUEvictOlderThan failed.  Expected %d evictions, got %d", 2, n)
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "pinned" {
+		t.Fatalf("TestLRUEvictOlderThan failed.  Expected [pinned] left, got %v", keys)
+	}
+}
+
+func TestLRUAddSticky(t *testing.T) {
+	var evicted []cm.Key
+	c := lru.NewLRU(0)
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	c.AddSticky("config", 1)
+	c.Add("a", 2)
+	c.Add("b", 3)
+
+	c.Clear()
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "config" {
+		t.Fatalf("TestLRUAddSticky failed.  Expected [config] after Clear, got %v", keys)
+	}
+	if len(evicted) != 2 {
+		t.Fatalf("TestLRUAddSticky failed.  Expected %d evictions, got %v", 2, evicted)
+	}
+
+	c.Add("config", 4)
+	c.Clear()
+	if val, ok := c.Get("config"); !ok || val != 4 {
+		t.Fatalf("TestLRUAddSticky failed.  Expected %d, got %v", 4, val)
+	}
+
+	c.ClearAll()
+	if c.Len() != 0 || len(evicted) != 3 {
+		t.Fatalf("TestLRUAddSticky failed.  Expected an empty cache after ClearAll, got %v", c.Keys())
+	}
+
+	c.AddSticky("config", 5)
+	c.Remove("config")
+	if c.Len() != 0 {
+		t.Fatal("TestLRUAddSticky failed.  Remove kept a sticky entry")
+	}
+
+	clears := []struct {
+		name  string
+		clear func(c *lru.LRU)
+	}{
+		{"clear_retain", (*lru.LRU).ClearRetain},
+		{"clear_async", func(c *lru.LRU) { <-c.ClearAsync() }},
+	}
+	for _, tt := range clears {
+		c := lru.NewLRU(0)
+		c.AddSticky("config", 1)
+		c.Add("a", 2)
+
+		tt.clear(c)
+		if keys := c.Keys(); len(keys) != 1 || keys[0] != "config" {
+			t.Fatalf("%s: expected [config] to survive, got %v", tt.name, keys)
+		}
+		c.Add("b", 3)
+		if c.Len() != 2 {
+			t.Fatalf("%s: cache unusable after clearing", tt.name)
+		}
+	}
+}
+
+func TestLRUPromoteCooldown(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	defer lru.SetNow(func() time.Time { return clock })()
+
+	c := lru.NewLRU(0)
+	c.PromoteCooldown = time.Minute
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	steps := []struct {
+		at       time.Duration
+		key      string
+		expected []cm.Key
+	}{
+		{30 * time.Second, "a", []cm.Key{"b", "a"}},
+		{61 * time.Second, "a", []cm.Key{"a", "b"}},
+		{62 * time.Second, "b", []cm.Key{"b", "a"}},
+		{90 * time.Second, "a", []cm.Key{"b", "a"}},
+		{121 * time.Second, "a", []cm.Key{"a", "b"}},
+	}
+	for _, tt := range steps {
+		clock = start.Add(tt.at)
+		c.Get(tt.key)
+		if keys := c.Keys(); !reflect.DeepEqual(keys, tt.expected) {
+			t.Fatalf("TestLRUPromoteCooldown failed at %v.  Expected %v, got %v", tt.at, tt.expected, keys)
+		}
+	}
+}
+
+func TestLRUOnEvictedSampled(t *testing.T) {
+	sampleTests := []struct {
+		name string
+		rate float64
+		min  int
+		max  int
+	}{
+		{"none", 0, 0, 0},
+		{"tenth", 0.1, 70, 130},
+		{"half", 0.5, 450, 550},
+		{"all", 1, 1000, 1000},
+	}
+	for _, tt := range sampleTests {
+		sampled := 0
+		lru := lru.NewLRU(1)
+		lru.Rand = rand.New(rand.NewSource(42))
+		lru.SampleRate = tt.rate
+		lru.OnEvictedSampled = func(k cm.Key, v cm.Value) {
+			sampled += 1
+		}
+
+		for i := 0; i <= 1000; i++ {
+			lru.Add(i, i)
+		}
+		if sampled < tt.min || sampled > tt.max {
+			t.Fatalf("%s: sampled %d of 1000 evictions; want %d to %d", tt.name, sampled, tt.min, tt.max)
+		}
+	}
+}
+
+func TestLRUMutate(t *testing.T) {
+	type account struct {
+		name    string
+		balance int
+	}
+
+	c := lru.NewLRU(0)
+	c.Add("alice", account{"alice", 10})
+	c.Add("bob", account{"bob", 20})
+
+	deposit := func(v cm.Value) cm.Value {
+		a := v.(account)
+		a.balance += 5
+		return a
+	}
+	if !c.Mutate("alice", deposit) {
+		t.Fatal("TestLRUMutate failed to mutate a cached value")
+	}
+	if val, ok := c.Peek("alice"); !ok || val != (account{"alice", 15}) {
+		t.Fatalf("TestLRUMutate failed.  Expected %v, got %v", account{"alice", 15}, val)
+	}
+	if keys := c.Keys(); keys[0] != "bob" {
+		t.Fatalf("TestLRUMutate changed the recency order to %v", keys)
+	}
+	if c.Mutate("carol", deposit) {
+		t.Fatal("TestLRUMutate mutated a missing key")
+	}
+}
+
+func TestLRUCanEvict(t *testing.T) {
+	var evicted []cm.Key
+	busy := map[cm.Key]bool{"a": true}
+
+	c := lru.NewLRU(2)
+	c.CanEvict = func(k cm.Key, v cm.Value) bool {
+		return !busy[k]
+	}
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("TestLRUCanEvict failed.  Expected evictions [b], got %v", evicted)
+	}
+
+	// with every entry vetoed the cache grows
+	busy["c"] = true
+	c.Add("d", 4)
+	if c.Len() != 3 || len(evicted) != 1 {
+		t.Fatalf("TestLRUCanEvict failed.  Expected 3 entries and no eviction, got %v, %v", c.Keys(), evicted)
+	}
+
+	delete(busy, "a")
+	if k, _, ok := c.RemoveOldest(); !ok || k != "a" {
+		t.Fatalf("TestLRUCanEvict failed.  Expected to remove a, got %v", k)
+	}
+}
+
+func TestLRURetainKeys(t *testing.T) {
+	var evicted []cm.Key
+	c := lru.NewLRU(0)
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Add(k, k)
+	}
+
+	valid := map[cm.Key]struct{}{"b": {}, "d": {}, "upstream_only": {}}
+	if n := c.RetainKeys(valid); n != 2 {
+		t.Fatalf("TestLRURetainKeys failed.  Expected %d removals, got %d", 2, n)
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []cm.Key{"d", "b"}) {
+		t.Fatalf("TestLRURetainKeys failed.  Expected [d b] left, got %v", keys)
+	}
+	if !reflect.DeepEqual(evicted, []cm.Key{"a", "c"}) {
+		t.Fatalf("TestLRURetainKeys failed.  Expected evictions [a c], got %v", evicted)
+	}
+}
+
+func TestLRUMinResidency(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	defer lru.SetNow(func() time.Time { return clock })()
+
+	type session struct {
+		id        string
+		keepUntil time.Time
+	}
+
+	var evicted []cm.Key
+	c := lru.NewLRU(2)
+	c.MinResidency = func(v cm.Value) time.Time {
+		return v.(session).keepUntil
+	}
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	c.Add("a", session{"a", start.Add(time.Minute)})
+	c.Add("b", session{"b", start})
+	c.Add("c", session{"c", start})
+	if !reflect.DeepEqual(evicted, []cm.Key{"b"}) {
+		t.Fatalf("TestLRUMinResidency failed.  Expected evictions [b], got %v", evicted)
+	}
+
+	clock = start.Add(time.Minute)
+	c.Add("d", session{"d", start})
+	if !reflect.DeepEqual(evicted, []cm.Key{"b", "a"}) {
+		t.Fatalf("TestLRUMinResidency failed.  Expected evictions [b a], got %v", evicted)
+	}
+
+	// with every entry protected the cache grows
+	c.Add("e", session{"e", clock.Add(time.Hour)})
+	c.Add("f", session{"f", clock.Add(time.Hour)})
+	c.Add("g", session{"g", clock.Add(time.Hour)})
+	if c.Len() != 3 {
+		t.Fatalf("TestLRUMinResidency failed.  Expected %d entries, got %v", 3, c.Keys())
+	}
+}
+
+func TestLRURestoreExactDropsSticky(t *testing.T) {
+	var evicted []cm.Key
+	c := lru.NewLRU(0)
+	c.OnEvicted = func(k cm.Key, v cm.Value) {
+		evicted = append(evicted, k)
+	}
+
+	c.AddSticky("cfg", 1)
+	c.SetMeta("cfg", "meta")
+	watch := c.Watch("cfg")
+
+	c.RestoreExact([]cm.Entry{{K: "a", V: 2}})
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []cm.Key{"a"}) {
+		t.Fatalf("TestLRURestoreExactDropsSticky failed.  Expected [a], got %v", keys)
+	}
+	if !reflect.DeepEqual(evicted, []cm.Key{"cfg"}) {
+		t.Fatalf("TestLRURestoreExactDropsSticky failed.  Expected evictions [cfg], got %v", evicted)
+	}
+	if _, ok := c.GetMeta("cfg"); ok {
+		t.Fatal("TestLRURestoreExactDropsSticky kept the metadata of a dropped entry")
+	}
+	select {
+	case <-watch:
+	default:
+		t.Fatal("TestLRURestoreExactDropsSticky did not fire the watcher of a dropped entry")
+	}
+}
+
+func TestLRUOnEvictedSampledClearAsync(t *testing.T) {
+	var mu sync.Mutex
+	sampled := 0
+	c := lru.NewLRU(10)
+	c.Rand = rand.New(rand.NewSource(42))
+	c.SampleRate = 0.5
+	c.OnEvictedSampled = func(k cm.Key, v cm.Value) {
+		mu.Lock()
+		sampled += 1
+		mu.Unlock()
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Add(i, i)
+	}
+	done := c.ClearAsync()
+
+	// the cache keeps sampling its own evictions while the clear reports
+	for i := 0; i < 100; i++ {
+		c.Add(i, i)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sampled == 0 {
+		t.Fatal("TestLRUOnEvictedSampledClearAsync sampled no evictions")
+	}
+}
@@ -30,18 +30,49 @@ type LRUK struct {
 	MaxEntries int
 	MaxHitting int
 
+	// MaxHistory is the maximum number of uncached keys whose accesses
+	// are counted. An Add of an uncached key stages its value in the
+	// history, so that a Get bringing the key to MaxHitting accesses can
+	// promote it. Once it is reached, the longest tracked key is
+	// forgotten first. Zero means no limit.
+	MaxHistory int
+
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	OnEvicted func(key cm.Key, value cm.Value)
 
-	ll    *list.List
-	count map[cm.Key]int
-	cache map[cm.Key]*list.Element
+	// OnClear optionally specifies a callback function to be executed
+	// once a Clear has completed, after any OnEvicted calls.
+	OnClear func()
+
+	ll      *list.List
+	history *list.List
+	count   map[cm.Key]*list.Element
+	cache   map[cm.Key]*list.Element
+}
+
+// lrukCount is the value held by each element of the history list.
+type lrukCount struct {
+	k cm.Key
+	n int
+
+	// v is the value staged by the latest Add of the key, if staged.
+	v      cm.Value
+	staged bool
 }
 
 // New creates a new Cache.
-// If maxEntries is zero, the cache has no limit.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
 func NewLRUK(maxEntries, maxHitting int) *LRUK {
+	return NewLRUKWithCapacity(maxEntries, maxHitting, 0)
+}
+
+// NewLRUKWithCapacity creates a new Cache whose internal map is pre-sized
+// to hold initialCap entries, avoiding repeated rehashing while it fills.
+func NewLRUKWithCapacity(maxEntries, maxHitting, initialCap int) *LRUK {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
 	if maxHitting <= 0 {
 		panic("MaxHitting must be larger than 0!")
 	}
@@ -49,17 +80,35 @@ func NewLRUK(maxEntries, maxHitting int) *LRUK {
 		MaxEntries: maxEntries,
 		MaxHitting: maxHitting,
 		ll:         list.New(),
-		count:      make(map[cm.Key]int),
-		cache:      make(map[cm.Key]*list.Element),
+		history:    list.New(),
+		count:      make(map[cm.Key]*list.Element),
+		cache:      make(map[cm.Key]*list.Element, initialCap),
 	}
 }
 
+// NewLRUKWithHistory creates a new Cache that counts the accesses of at
+// most maxHistory uncached keys, bounding the memory taken by keys that
+// are accessed too rarely to be cached. If maxHistory is zero, the
+// history has no limit; it must not be negative.
+func NewLRUKWithHistory(maxEntries, maxHitting, maxHistory int) *LRUK {
+	if maxHistory < 0 {
+		panic("maxHistory must not be negative!")
+	}
+
+	lruk := NewLRUK(maxEntries, maxHitting)
+	lruk.MaxHistory = maxHistory
+	return lruk
+}
+
 // Add adds a value to the cache.
 func (lruk *LRUK) Add(k cm.Key, v cm.Value) {
+	strictCheck("LRUK", lruk.cache != nil, lruk.MaxEntries >= 0)
+
 	if lruk.cache == nil {
 		lruk.cache = make(map[cm.Key]*list.Element)
 		lruk.ll = list.New()
-		lruk.count = make(map[cm.Key]int)
+		lruk.history = list.New()
+		lruk.count = make(map[cm.Key]*list.Element)
 	}
 
 	if ee, ok := lruk.cache[k]; ok {
@@ -68,15 +117,72 @@ func (lruk *LRUK) Add(k cm.Key, v cm.Value) {
 		return
 	}
 
-	if _, ok := lruk.count[k]; !ok {
-		lruk.count[k] = 0
+	if c := lruk.hit(k); c.n < lruk.MaxHitting {
+		c.v, c.staged = v, true
+		return
+	}
+
+	lruk.promote(k, v)
+}
+
+// PromoteNow adds a value straight into the cache without waiting for
+// MaxHitting accesses, for warming the cache with keys known to be hot.
+func (lruk *LRUK) PromoteNow(k cm.Key, v cm.Value) {
+	if lruk.cache == nil {
+		lruk.cache = make(map[cm.Key]*list.Element)
+		lruk.ll = list.New()
+		lruk.history = list.New()
+		lruk.count = make(map[cm.Key]*list.Element)
 	}
-	lruk.count[k] += 1
-	if lruk.count[k] < lruk.MaxHitting {
+
+	if ee, ok := lruk.cache[k]; ok {
+		lruk.ll.MoveToFront(ee)
+		ee.Value.(*cm.Entry).V = v
 		return
 	}
 
-	delete(lruk.count, k)
+	lruk.promote(k, v)
+}
+
+// SetMaxHitting changes the number of accesses needed to cache a key.
+// When it is lowered, tracked keys that already reach the new threshold
+// and have a staged value are promoted straight away; those without one,
+// having only been read, are promoted by their next Add.
+func (lruk *LRUK) SetMaxHitting(maxHitting int) {
+	if maxHitting <= 0 {
+		panic("MaxHitting must be larger than 0!")
+	}
+
+	lower := maxHitting < lruk.MaxHitting
+	lruk.MaxHitting = maxHitting
+	if lower {
+		lruk.reconcileCounts()
+	}
+}
+
+// reconcileCounts promotes the tracked keys that reach MaxHitting and have
+// a staged value, from the longest tracked, so the most recently tracked
+// ends up most recently used.
+func (lruk *LRUK) reconcileCounts() {
+	if lruk.cache == nil {
+		return
+	}
+
+	for e := lruk.history.Back(); e != nil; {
+		prev := e.Prev()
+		if c := e.Value.(*lrukCount); c.staged && (c.n >= lruk.MaxHitting) {
+			lruk.promote(c.k, c.v)
+		}
+		e = prev
+	}
+}
+
+// promote moves a key from the access history into the cache.
+func (lruk *LRUK) promote(k cm.Key, v cm.Value) {
+	if he, ok := lruk.count[k]; ok {
+		lruk.history.Remove(he)
+		delete(lruk.count, k)
+	}
 
 	if (lruk.MaxEntries > 0) && (lruk.ll.Len() == lruk.MaxEntries) {
 		b := lruk.ll.Back()
@@ -88,8 +194,29 @@ func (lruk *LRUK) Add(k cm.Key, v cm.Value) {
 	lruk.cache[k] = ee
 }
 
+// hit counts an access to an uncached key and returns its record,
+// forgetting the longest tracked keys beyond MaxHistory.
+func (lruk *LRUK) hit(k cm.Key) *lrukCount {
+	if he, ok := lruk.count[k]; ok {
+		c := he.Value.(*lrukCount)
+		c.n += 1
+		return c
+	}
+
+	c := &lrukCount{k: k, n: 1}
+	lruk.count[k] = lruk.history.PushFront(c)
+	for (lruk.MaxHistory > 0) && (lruk.history.Len() > lruk.MaxHistory) {
+		b := lruk.history.Back()
+		lruk.history.Remove(b)
+		delete(lruk.count, b.Value.(*lrukCount).k)
+	}
+	return c
+}
+
 // Get looks up a key's value from the cache.
 func (lruk *LRUK) Get(k cm.Key) (v cm.Value, ok bool) {
+	strictCheck("LRUK", lruk.cache != nil, lruk.MaxEntries >= 0)
+
 	if lruk.cache == nil {
 		return nil, false
 	}
@@ -99,14 +226,39 @@ func (lruk *LRUK) Get(k cm.Key) (v cm.Value, ok bool) {
 		return ee.Value.(*cm.Entry).V, true
 	}
 
-	if _, ok := lruk.count[k]; !ok {
-		lruk.count[k] = 0
+	// a read that reaches MaxHitting promotes a key whose value was
+	// staged by an earlier Add
+	if c := lruk.hit(k); c.staged && (c.n >= lruk.MaxHitting) {
+		lruk.promote(k, c.v)
+		return c.v, true
 	}
-	lruk.count[k] += 1
 
 	return nil, false
 }
 
+// Peek looks up a key's value from the cache without updating its recency
+// or counting an access.
+func (lruk *LRUK) Peek(k cm.Key) (v cm.Value, ok bool) {
+	if ee, hit := lruk.cache[k]; hit {
+		return ee.Value.(*cm.Entry).V, true
+	}
+	return nil, false
+}
+
+// Keys returns all cached keys from the most to the least recently used.
+// Keys that are only counted in the access history are not included.
+func (lruk *LRUK) Keys() []cm.Key {
+	if lruk.cache == nil {
+		return nil
+	}
+
+	keys := make([]cm.Key, 0, lruk.ll.Len())
+	for e := lruk.ll.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*cm.Entry).K)
+	}
+	return keys
+}
+
 // Remove removes the provided key from the cache.
 func (lruk *LRUK) Remove(k cm.Key) {
 	if lruk.cache == nil {
@@ -138,7 +290,12 @@ func (lruk *LRUK) Clear() {
 	}
 
 	lruk.ll = nil
+	lruk.history = nil
 	lruk.count = nil
 
 	lruk.cache = nil
+
+	if lruk.OnClear != nil {
+		lruk.OnClear()
+	}
 }
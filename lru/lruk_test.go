@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
 )
 
 func TestLRUKGet(t *testing.T) {
@@ -26,7 +27,8 @@ func TestLRUKGet(t *testing.T) {
 		expectedOk bool
 	}{
 		{"string_hit", 1, 1, "myKey", "myKey", true},
-		{"string_hit", 2, 1, "myKey", "myKey", false},
+		{"string_read_promotes", 2, 1, "myKey", "myKey", true},
+		{"string_too_few", 3, 1, "myKey", "myKey", false},
 		{"string_miss", 1, 1, "myKey", "nonsense", false},
 		{"simple_struct_hit", 2, 3, simpleStruct{1, "two"}, simpleStruct{1, "two"}, true},
 		{"simple_struct_miss", 2, 2, simpleStruct{1, "two"}, simpleStruct{0, "noway"}, false},
@@ -61,3 +63,127 @@ func TestLRUKRemove(t *testing.T) {
 		t.Fatal("TestLRUKRemove returned a removed entry")
 	}
 }
+
+func TestLRUKWithCapacity(t *testing.T) {
+	lruk := lru.NewLRUKWithCapacity(0, 3, 16)
+	lruk.Add("myKey", 1234)
+	if _, ok := lruk.Get("myKey"); ok {
+		t.Fatal("TestLRUKWithCapacity returned an entry below MaxHitting")
+	}
+	lruk.Add("myKey", 1234)
+	if val, ok := lruk.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestLRUKWithCapacity failed.  Expected %d, got %v", 1234, val)
+	}
+}
+
+func TestLRUKOnClear(t *testing.T) {
+	var events []string
+	lruk := lru.NewLRUK(0, 1)
+	lruk.OnEvicted = func(k cm.Key, v cm.Value) { events = append(events, "evicted") }
+	lruk.OnClear = func() { events = append(events, "cleared") }
+
+	lruk.Add("a", 1)
+	lruk.Add("b", 2)
+	lruk.Clear()
+	if len(events) != 3 || events[2] != "cleared" {
+		t.Fatalf("TestLRUKOnClear failed.  Expected [evicted evicted cleared], got %v", events)
+	}
+}
+
+func TestLRUKPromoteNow(t *testing.T) {
+	lruk := lru.NewLRUK(0, 3)
+	lruk.Add("tracked", 1)
+	lruk.PromoteNow("tracked", 2)
+	lruk.PromoteNow("hot", 1234)
+
+	if val, ok := lruk.Get("hot"); !ok || val != 1234 {
+		t.Fatalf("TestLRUKPromoteNow failed.  Expected %d, got %v", 1234, val)
+	}
+	if val, ok := lruk.Get("tracked"); !ok || val != 2 {
+		t.Fatalf("TestLRUKPromoteNow failed.  Expected %d, got %v", 2, val)
+	}
+	if n := lruk.Len(); n != 2 {
+		t.Fatalf("TestLRUKPromoteNow failed.  Expected len %d, got %d", 2, n)
+	}
+}
+
+func TestLRUKWithHistory(t *testing.T) {
+	lruk := lru.NewLRUKWithHistory(10, 3, 3)
+	for i := 0; i < 100; i++ {
+		lruk.Get(i)
+		if n := lruk.HistoryLen(); n > 3 {
+			t.Fatalf("TestLRUKWithHistory failed.  Expected at most %d tracked keys, got %d", 3, n)
+		}
+	}
+
+	// 99 is still tracked, so two more accesses promote it; 0 was
+	// forgotten and starts counting again
+	lruk.Add(99, 99)
+	lruk.Add(0, 0)
+	if _, ok := lruk.Get(99); !ok {
+		t.Fatal("TestLRUKWithHistory did not promote a tracked key")
+	}
+	if _, ok := lruk.Get(0); ok {
+		t.Fatal("TestLRUKWithHistory promoted a forgotten key")
+	}
+
+	unbounded := lru.NewLRUK(10, 3)
+	for i := 0; i < 100; i++ {
+		unbounded.Get(i)
+	}
+	if n := unbounded.HistoryLen(); n != 100 {
+		t.Fatalf("TestLRUKWithHistory failed.  Expected %d tracked keys without a bound, got %d", 100, n)
+	}
+}
+
+func TestLRUKReadPromotion(t *testing.T) {
+	lruk := lru.NewLRUK(0, 3)
+	lruk.Add("myKey", 1234)
+	if _, ok := lruk.Get("myKey"); ok {
+		t.Fatal("TestLRUKReadPromotion promoted after 2 accesses")
+	}
+	if val, ok := lruk.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestLRUKReadPromotion failed.  Expected %d, got %v", 1234, val)
+	}
+	if lruk.Len() != 1 {
+		t.Fatalf("TestLRUKReadPromotion failed.  Expected %d cached entry, got %d", 1, lruk.Len())
+	}
+
+	// without a staged value reads alone never promote
+	for i := 0; i < 5; i++ {
+		if _, ok := lruk.Get("unseen"); ok {
+			t.Fatal("TestLRUKReadPromotion promoted a key that was never added")
+		}
+	}
+}
+
+func TestLRUKSetMaxHitting(t *testing.T) {
+	lruk := lru.NewLRUK(0, 4)
+	lruk.Add("a", 1)
+	lruk.Add("a", 1)
+	lruk.Add("b", 2)
+	lruk.Add("b", 2)
+	lruk.Add("c", 3)
+	lruk.Get("read")
+	lruk.Get("read")
+
+	lruk.SetMaxHitting(2)
+	if keys := lruk.Len(); keys != 2 {
+		t.Fatalf("TestLRUKSetMaxHitting failed.  Expected %d promoted entries, got %d", 2, keys)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, ok := lruk.Get(k); !ok {
+			t.Fatalf("TestLRUKSetMaxHitting did not promote %q", k)
+		}
+	}
+
+	// "read" reached the threshold with no value to promote; its next
+	// Add promotes it, while "c" still needs another access
+	lruk.Add("read", 4)
+	if val, ok := lruk.Get("read"); !ok || val != 4 {
+		t.Fatalf("TestLRUKSetMaxHitting failed.  Expected %d, got %v", 4, val)
+	}
+	if lruk.Len() != 3 {
+		t.Fatalf("TestLRUKSetMaxHitting failed.  Expected %d entries, got %d", 3, lruk.Len())
+	}
+}
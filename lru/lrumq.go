@@ -2,6 +2,7 @@ package lru
 
 import (
 	"container/list"
+	"strconv"
 
 	cm "goalgutil/macros/cache_macro"
 )
@@ -36,16 +37,296 @@ import (
 
 type LRUMQ struct {
 	MaxEntries int
-	NumQueues  int
+
+	// NumQueues is the number of priority queues. If it is zero when the
+	// cache is first used, a single queue is kept and LifeTime, if unset,
+	// defaults to MaxEntries.
+	NumQueues int
+
+	// HistorySize bounds Q-history, the record of recently evicted keys and
+	// their access counts. It is independent of MaxEntries, since MQ keeps
+	// more indexes than cached entries; the oldest records are dropped
+	// first. Zero keeps no history.
+	HistorySize int
+
+	// LifeTime is the number of accesses to the cache an entry may go
+	// without being accessed before it is demoted one queue. Zero disables
+	// demotion.
+	LifeTime int
 
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	OnEvicted func(k cm.Key, v cm.Value)
 
-	ll     *list.List
-	fifo   *list.List
-	cache  map[cm.Key]*list.Element
-	qcount map[cm.Key]*list.Element
-
+	clock    int
+	queues   []*list.List
+	cache    map[cm.Key]*list.Element
+	history  *list.List
 	qhistory map[cm.Key]*list.Element
 }
+
+type mqEntry struct {
+	cm.Entry
+
+	freq   int
+	queue  int
+	expire int
+}
+
+type mqRecord struct {
+	k    cm.Key
+	freq int
+}
+
+// New creates a new Cache with numQueues priority queues and a Q-history of
+// historySize keys. LifeTime defaults to maxEntries.
+// If maxEntries is zero, the cache has no limit; it must not be negative.
+func NewLRUMQ(maxEntries, numQueues, historySize int) *LRUMQ {
+	if maxEntries < 0 {
+		panic("maxEntries must not be negative!")
+	}
+	if numQueues <= 0 {
+		panic("numQueues must be larger than 0!")
+	}
+	if historySize < 0 {
+		panic("historySize must not be negative!")
+	}
+
+	mq := &LRUMQ{
+		MaxEntries:  maxEntries,
+		NumQueues:   numQueues,
+		HistorySize: historySize,
+		LifeTime:    maxEntries,
+	}
+	mq.init()
+	return mq
+}
+
+func (mq *LRUMQ) init() {
+	if mq.NumQueues <= 0 {
+		mq.NumQueues = 1
+		if mq.LifeTime == 0 {
+			mq.LifeTime = mq.MaxEntries
+		}
+	}
+
+	mq.clock = 0
+	mq.queues = make([]*list.List, mq.NumQueues)
+	for i := range mq.queues {
+		mq.queues[i] = list.New()
+	}
+	mq.cache = make(map[cm.Key]*list.Element)
+	mq.history = list.New()
+	mq.qhistory = make(map[cm.Key]*list.Element)
+}
+
+// Add adds a value to the cache.
+func (mq *LRUMQ) Add(k cm.Key, v cm.Value) {
+	strictCheck("LRUMQ", mq.cache != nil, mq.MaxEntries >= 0)
+
+	if mq.cache == nil {
+		mq.init()
+	}
+	mq.clock += 1
+
+	if ee, ok := mq.cache[k]; ok {
+		ee.Value.(*mqEntry).V = v
+		mq.access(ee)
+		mq.adjust()
+		return
+	}
+
+	// a key found in Q-history resumes its access count
+	freq := 1
+	if he, ok := mq.qhistory[k]; ok {
+		freq += he.Value.(*mqRecord).freq
+		mq.history.Remove(he)
+		delete(mq.qhistory, k)
+	}
+
+	if (mq.MaxEntries > 0) && (len(mq.cache) >= mq.MaxEntries) {
+		mq.evict()
+	}
+
+	kv := &mqEntry{Entry: cm.Entry{K: k, V: v}, freq: freq}
+	kv.queue = mq.queueFor(freq)
+	kv.expire = mq.clock + mq.LifeTime
+	mq.cache[k] = mq.queues[kv.queue].PushFront(kv)
+	mq.adjust()
+}
+
+// Get looks up a key's value from the cache.
+func (mq *LRUMQ) Get(k cm.Key) (v cm.Value, ok bool) {
+	strictCheck("LRUMQ", mq.cache != nil, mq.MaxEntries >= 0)
+
+	if mq.cache == nil {
+		return nil, false
+	}
+	mq.clock += 1
+
+	ee, hit := mq.cache[k]
+	if hit {
+		mq.access(ee)
+		v = ee.Value.(*mqEntry).V
+	}
+	mq.adjust()
+	return v, hit
+}
+
+// Peek looks up a key's value from the cache without counting an access.
+func (mq *LRUMQ) Peek(k cm.Key) (v cm.Value, ok bool) {
+	if ee, hit := mq.cache[k]; hit {
+		return ee.Value.(*mqEntry).V, true
+	}
+	return nil, false
+}
+
+// Keys returns all cached keys, from the highest queue to the lowest and
+// within each queue from the most to the least recently used. Keys only
+// remembered in Q-history are not included.
+func (mq *LRUMQ) Keys() []cm.Key {
+	keys := make([]cm.Key, 0, len(mq.cache))
+	for q := len(mq.queues) - 1; q >= 0; q-- {
+		for e := mq.queues[q].Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*mqEntry).K)
+		}
+	}
+	return keys
+}
+
+// Remove removes the provided key from the cache and its Q-history.
+func (mq *LRUMQ) Remove(k cm.Key) {
+	if mq.cache == nil {
+		return
+	}
+
+	if ee, hit := mq.cache[k]; hit {
+		mq.queues[ee.Value.(*mqEntry).queue].Remove(ee)
+		delete(mq.cache, k)
+	}
+	if he, hit := mq.qhistory[k]; hit {
+		mq.history.Remove(he)
+		delete(mq.qhistory, k)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (mq *LRUMQ) Len() int {
+	return len(mq.cache)
+}
+
+// QueueLens returns the number of entries in each queue, keyed "q0" to
+// "qN-1" for N NumQueues, and the number of keys in Q-history, keyed
+// "history".
+func (mq *LRUMQ) QueueLens() map[string]int {
+	lens := map[string]int{"history": 0}
+	for i := 0; i < mq.NumQueues; i++ {
+		lens["q"+strconv.Itoa(i)] = 0
+	}
+	if mq.cache == nil {
+		return lens
+	}
+
+	for i, q := range mq.queues {
+		lens["q"+strconv.Itoa(i)] = q.Len()
+	}
+	lens["history"] = mq.history.Len()
+	return lens
+}
+
+// Clear purges all entries from the cache and its Q-history.
+func (mq *LRUMQ) Clear() {
+	if mq.OnEvicted != nil {
+		for _, e := range mq.cache {
+			kv := e.Value.(*mqEntry)
+			mq.OnEvicted(kv.K, kv.V)
+		}
+	}
+
+	mq.queues = nil
+	mq.cache = nil
+	mq.history = nil
+	mq.qhistory = nil
+}
+
+// queueFor returns the queue for an access count: log2(freq), capped at
+// the highest queue.
+func (mq *LRUMQ) queueFor(freq int) int {
+	q := 0
+	for freq > 1 && q < mq.NumQueues-1 {
+		freq >>= 1
+		q += 1
+	}
+	return q
+}
+
+// access counts an access to an entry and moves it to the front of the
+// queue its new count belongs to.
+func (mq *LRUMQ) access(ee *list.Element) {
+	kv := ee.Value.(*mqEntry)
+	kv.freq += 1
+	kv.expire = mq.clock + mq.LifeTime
+
+	mq.queues[kv.queue].Remove(ee)
+	kv.queue = mq.queueFor(kv.freq)
+	mq.cache[kv.K] = mq.queues[kv.queue].PushFront(kv)
+}
+
+// adjust demotes the least recently used entry of each queue above Q0 by
+// one queue once its lifetime has passed.
+func (mq *LRUMQ) adjust() {
+	if mq.LifeTime <= 0 {
+		return
+	}
+
+	for q := 1; q < mq.NumQueues; q++ {
+		b := mq.queues[q].Back()
+		if b == nil {
+			continue
+		}
+		kv := b.Value.(*mqEntry)
+		if kv.expire >= mq.clock {
+			continue
+		}
+
+		mq.queues[q].Remove(b)
+		kv.queue = q - 1
+		kv.expire = mq.clock + mq.LifeTime
+		mq.cache[kv.K] = mq.queues[kv.queue].PushFront(kv)
+	}
+}
+
+// evict removes the least recently used entry of the lowest non-empty
+// queue and records its key in Q-history.
+func (mq *LRUMQ) evict() {
+	for _, q := range mq.queues {
+		b := q.Back()
+		if b == nil {
+			continue
+		}
+
+		kv := b.Value.(*mqEntry)
+		q.Remove(b)
+		delete(mq.cache, kv.K)
+		mq.remember(kv.K, kv.freq)
+		if mq.OnEvicted != nil {
+			mq.OnEvicted(kv.K, kv.V)
+		}
+		return
+	}
+}
+
+// remember records an evicted key in Q-history, dropping the oldest
+// records beyond HistorySize.
+func (mq *LRUMQ) remember(k cm.Key, freq int) {
+	if mq.HistorySize <= 0 {
+		return
+	}
+
+	mq.qhistory[k] = mq.history.PushFront(&mqRecord{k: k, freq: freq})
+	for mq.history.Len() > mq.HistorySize {
+		b := mq.history.Back()
+		mq.history.Remove(b)
+		delete(mq.qhistory, b.Value.(*mqRecord).k)
+	}
+}
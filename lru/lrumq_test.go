@@ -0,0 +1,116 @@
+package lru_test
+
+import (
+	"reflect"
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestLRUMQGet(t *testing.T) {
+	mq := lru.NewLRUMQ(2, 4, 2)
+	mq.Add("myKey", 1234)
+	if val, ok := mq.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestLRUMQGet failed.  Expected 1234, got %v", val)
+	}
+	if _, ok := mq.Get("nonsense"); ok {
+		t.Fatal("TestLRUMQGet returned a value for a missing key")
+	}
+}
+
+func TestLRUMQHistorySize(t *testing.T) {
+	mq := lru.NewLRUMQ(2, 4, 3)
+	for i := 0; i < 6; i++ {
+		mq.Add(i, i)
+	}
+
+	if mq.Len() != 2 {
+		t.Fatalf("TestLRUMQHistorySize failed.  Expected %d, got %v", 2, mq.Len())
+	}
+	if keys := mq.HistoryKeys(); !reflect.DeepEqual(keys, []interface{}{3, 2, 1}) {
+		t.Fatalf("TestLRUMQHistorySize failed.  Expected [3 2 1], got %v", keys)
+	}
+}
+
+func TestLRUMQReactivation(t *testing.T) {
+	reactivationTests := []struct {
+		name        string
+		historySize int
+		expectedOk  bool
+	}{
+		{"in_history", 4, true},
+		{"no_history", 0, false},
+	}
+	for _, tt := range reactivationTests {
+		mq := lru.NewLRUMQ(2, 4, tt.historySize)
+		mq.LifeTime = 0
+
+		// "a" is evicted and re-added; with history it resumes its
+		// access count and outranks the keys added after it
+		mq.Add("a", 1)
+		mq.Add("b", 2)
+		mq.Add("c", 3)
+		mq.Add("a", 1)
+		mq.Add("d", 4)
+		mq.Add("e", 5)
+
+		if _, ok := mq.Get("a"); ok != tt.expectedOk {
+			t.Fatalf("%s: cache hit = %v; want %v", tt.name, ok, tt.expectedOk)
+		}
+		if len(mq.HistoryKeys()) > tt.historySize {
+			t.Fatalf("%s: history holds %v; want at most %d keys", tt.name, mq.HistoryKeys(), tt.historySize)
+		}
+	}
+}
+
+func TestLRUMQRemove(t *testing.T) {
+	mq := lru.NewLRUMQ(1, 2, 2)
+	mq.Add("a", 1)
+	mq.Add("b", 2)
+	mq.Remove("a")
+	mq.Remove("b")
+
+	if mq.Len() != 0 || len(mq.HistoryKeys()) != 0 {
+		t.Fatalf("TestLRUMQRemove failed.  Expected empty cache and history, got %d, %v", mq.Len(), mq.HistoryKeys())
+	}
+}
+
+func TestLRUMQQueueLens(t *testing.T) {
+	mq := lru.NewLRUMQ(3, 3, 2)
+	mq.LifeTime = 0
+	mq.Add("a", 1)
+	mq.Add("b", 2)
+	mq.Add("c", 3)
+	for i := 0; i < 3; i++ {
+		mq.Get("a")
+	}
+	mq.Get("b")
+	mq.Add("d", 4)
+
+	expected := map[string]int{"q0": 1, "q1": 1, "q2": 1, "history": 1}
+	if lens := mq.QueueLens(); !reflect.DeepEqual(lens, expected) {
+		t.Fatalf("TestLRUMQQueueLens failed.  Expected %v, got %v", expected, lens)
+	}
+}
+
+func TestLRUMQZeroValue(t *testing.T) {
+	mq := &lru.LRUMQ{}
+	mq.Add("a", 1)
+	mq.Add("b", 2)
+	if v, ok := mq.Get("a"); !ok || v != 1 {
+		t.Fatalf("TestLRUMQZeroValue failed.  Expected 1, got %v", v)
+	}
+	if mq.NumQueues != 1 {
+		t.Fatalf("TestLRUMQZeroValue failed.  Expected 1 queue, got %d", mq.NumQueues)
+	}
+
+	mq = lru.NewLRUMQ(2, 2, 0)
+	mq.Clear()
+	mq.NumQueues = 0
+	mq.Add("a", 1)
+	mq.Add("b", 2)
+	mq.Add("c", 3)
+	if mq.Len() != 2 {
+		t.Fatalf("TestLRUMQZeroValue failed.  Expected 2, got %d", mq.Len())
+	}
+}
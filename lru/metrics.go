@@ -0,0 +1,30 @@
+package lru
+
+// Metrics is a snapshot of the size and activity of a cache, suited to
+// scrape-based monitoring. The counters only grow, from the creation of
+// the cache.
+type Metrics struct {
+	Size     int
+	Capacity int
+
+	// Hits and Misses count the lookups made with Get; a miss served by
+	// Spill still counts as a miss.
+	Hits   uint64
+	Misses uint64
+
+	// Evictions counts the entries reported to OnEvicted other than by a
+	// Clear: those evicted for capacity, by RemoveOldest, Evict,
+	// EvictOlderThan and the like, or dropped by SwapContents.
+	Evictions uint64
+}
+
+// Collect returns a snapshot of the cache's metrics.
+func (lru *LRU) Collect() Metrics {
+	return Metrics{
+		Size:      lru.Len(),
+		Capacity:  lru.MaxEntries,
+		Hits:      lru.hits,
+		Misses:    lru.misses,
+		Evictions: lru.evictions,
+	}
+}
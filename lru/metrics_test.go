@@ -0,0 +1,29 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestLRUCollect(t *testing.T) {
+	c := lru.NewLRU(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Get("b")
+	c.Get("a")
+	c.Get("c")
+
+	expected := lru.Metrics{Size: 2, Capacity: 2, Hits: 2, Misses: 1, Evictions: 1}
+	if m := c.Collect(); m != expected {
+		t.Fatalf("TestLRUCollect failed.  Expected %+v, got %+v", expected, m)
+	}
+
+	c.Clear()
+	c.Get("b")
+	expected = lru.Metrics{Size: 0, Capacity: 2, Hits: 2, Misses: 2, Evictions: 1}
+	if m := c.Collect(); m != expected {
+		t.Fatalf("TestLRUCollect failed after Clear.  Expected %+v, got %+v", expected, m)
+	}
+}
@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"container/ring"
+	"time"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// OpRecord describes a single operation performed on a cache.
+type OpRecord struct {
+	Op   string
+	Key  cm.Key
+	Time time.Time
+}
+
+// WithOpLog makes the cache record its last size Add, Get, Remove and
+// Clear operations for RecentOps. A size of zero turns recording off,
+// which is the default. It returns the cache to allow chaining.
+func (lru *LRU) WithOpLog(size int) *LRU {
+	if size > 0 {
+		lru.oplog = ring.New(size)
+	} else {
+		lru.oplog = nil
+	}
+	return lru
+}
+
+// RecentOps returns the recorded operations from the oldest to the newest.
+func (lru *LRU) RecentOps() []OpRecord {
+	if lru.oplog == nil {
+		return nil
+	}
+
+	ops := make([]OpRecord, 0, lru.oplog.Len())
+	lru.oplog.Do(func(x any) {
+		if x != nil {
+			ops = append(ops, x.(OpRecord))
+		}
+	})
+	return ops
+}
+
+func (lru *LRU) logOp(op string, k cm.Key) {
+	if lru.oplog == nil {
+		return
+	}
+
+	lru.oplog.Value = OpRecord{Op: op, Key: k, Time: now()}
+	lru.oplog = lru.oplog.Next()
+}
+
+// WithEvictionLog makes the cache record the key of every entry it evicts,
+// in order, for EvictionLog. This is useful for comparing eviction
+// sequences between versions; it is off by default. It returns the cache
+// to allow chaining.
+func (lru *LRU) WithEvictionLog() *LRU {
+	lru.evictionLog = []cm.Key{}
+	return lru
+}
+
+// EvictionLog returns the keys evicted since WithEvictionLog was called,
+// from the first to the most recent. Entries removed by Remove or Clear
+// are not evictions and are not listed.
+func (lru *LRU) EvictionLog() []cm.Key {
+	if lru.evictionLog == nil {
+		return nil
+	}
+	return append([]cm.Key(nil), lru.evictionLog...)
+}
+
+func (lru *LRU) logEviction(k cm.Key) {
+	if lru.evictionLog != nil {
+		lru.evictionLog = append(lru.evictionLog, k)
+	}
+}
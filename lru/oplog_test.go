@@ -0,0 +1,78 @@
+package lru_test
+
+import (
+	"testing"
+	"time"
+
+	"goalgutil/lru"
+)
+
+func TestLRUOpLog(t *testing.T) {
+	clock := time.Unix(0, 0)
+	defer lru.SetNow(func() time.Time { clock = clock.Add(time.Second); return clock })()
+
+	c := lru.NewLRU(0)
+	c.Add("a", 1)
+	if ops := c.RecentOps(); ops != nil {
+		t.Fatalf("TestLRUOpLog recorded %v with the log disabled", ops)
+	}
+
+	c.WithOpLog(3)
+	c.Add("a", 1)
+	c.Get("a")
+	if ops := c.RecentOps(); len(ops) != 2 || ops[0].Op != "add" || ops[1].Op != "get" {
+		t.Fatalf("TestLRUOpLog failed.  Expected [add get], got %v", ops)
+	}
+
+	c.Remove("a")
+	c.Get("b")
+	c.Clear()
+	ops := c.RecentOps()
+	expected := []lru.OpRecord{
+		{Op: "remove", Key: "a"},
+		{Op: "get", Key: "b"},
+		{Op: "clear"},
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("TestLRUOpLog failed.  Expected %d ops, got %v", len(expected), ops)
+	}
+	for i := range expected {
+		if ops[i].Op != expected[i].Op || ops[i].Key != expected[i].Key {
+			t.Fatalf("TestLRUOpLog failed.  Expected %v at %d, got %v", expected[i], i, ops[i])
+		}
+		if i > 0 && !ops[i].Time.After(ops[i-1].Time) {
+			t.Fatalf("TestLRUOpLog recorded ops out of order: %v", ops)
+		}
+	}
+}
+
+func TestLRUEvictionLog(t *testing.T) {
+	c := lru.NewLRU(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if keys := c.EvictionLog(); keys != nil {
+		t.Fatalf("TestLRUEvictionLog recorded %v with the log disabled", keys)
+	}
+
+	c.WithEvictionLog()
+	c.Get("b")
+	c.Add("d", 4)
+	c.Add("e", 5)
+	c.Remove("e")
+	c.Add("f", 6)
+	c.Add("g", 7)
+	c.Evict(1)
+	c.Clear()
+
+	expected := []interface{}{"c", "b", "d", "f"}
+	keys := c.EvictionLog()
+	if len(keys) != len(expected) {
+		t.Fatalf("TestLRUEvictionLog failed.  Expected %v, got %v", expected, keys)
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Fatalf("TestLRUEvictionLog failed.  Expected %v, got %v", expected, keys)
+		}
+	}
+}
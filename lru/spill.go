@@ -0,0 +1,30 @@
+package lru
+
+import (
+	cm "goalgutil/macros/cache_macro"
+)
+
+// Spill is a slower store, such as a disk or a remote cache, that catches
+// entries overflowing a bounded LRU. Entries evicted by RemoveOldest,
+// EvictOlderThan or for capacity are stored in it before OnEvicted is
+// called; removed or cleared entries are not. A Get that misses the cache
+// loads the key from it and, on a hit, adds the value back to the cache,
+// which may in turn spill another entry. Spilled entries are never
+// deleted from the Spill by the cache.
+type Spill interface {
+	Store(k cm.Key, v cm.Value)
+	Load(k cm.Key) (v cm.Value, ok bool)
+}
+
+// unspill loads a missed key from Spill, adding it back to the cache.
+func (lru *LRU) unspill(k cm.Key) (v cm.Value, ok bool) {
+	if lru.Spill == nil {
+		return nil, false
+	}
+
+	if v, ok = lru.Spill.Load(k); !ok {
+		return nil, false
+	}
+	lru.Add(k, v)
+	return v, true
+}
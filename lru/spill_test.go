@@ -0,0 +1,50 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+type mapSpill map[cm.Key]cm.Value
+
+func (s mapSpill) Store(k cm.Key, v cm.Value) {
+	s[k] = v
+}
+
+func (s mapSpill) Load(k cm.Key) (cm.Value, bool) {
+	v, ok := s[k]
+	return v, ok
+}
+
+func TestLRUSpill(t *testing.T) {
+	spill := mapSpill{}
+	c := lru.NewLRU(2)
+	c.Spill = spill
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if val, ok := spill["a"]; !ok || val != 1 || len(spill) != 1 {
+		t.Fatalf("TestLRUSpill failed.  Expected a spilled, got %v", spill)
+	}
+
+	if val, ok := c.Get("a"); !ok || val != 1 {
+		t.Fatalf("TestLRUSpill failed.  Expected %d, got %v", 1, val)
+	}
+	if _, ok := c.Peek("a"); !ok {
+		t.Fatal("TestLRUSpill did not repopulate the cache from the spill")
+	}
+	if _, ok := spill["b"]; !ok {
+		t.Fatalf("TestLRUSpill failed.  Expected b spilled by the reload, got %v", spill)
+	}
+
+	c.Remove("c")
+	if _, ok := spill["c"]; ok {
+		t.Fatal("TestLRUSpill spilled a removed entry")
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("TestLRUSpill returned a value for a missing key")
+	}
+}
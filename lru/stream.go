@@ -0,0 +1,130 @@
+package lru
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// Entries are streamed one frame at a time: a 4-byte big-endian length
+// followed by that many bytes holding the gob encoding of a cm.Entry.
+// Each frame carries its own gob type information, so frames can be
+// decoded independently and a cache can be written or read without
+// holding all of its entries in memory at once.
+//
+// Keys and values travel as interface values, so any concrete type other
+// than the gob builtins must be registered with gob.Register first.
+//
+// WithCodec replaces this stream with a Codec of the caller's choice.
+
+// MaxFrameSize bounds the length of a frame ReadFrom accepts, so that a
+// corrupt or hostile length prefix cannot make it allocate up to 4GiB.
+var MaxFrameSize uint32 = 16 << 20
+
+// WriteTo writes all entries to w, from the most to the least recently
+// used. It does not change the recency of any entry.
+func (lru *LRU) WriteTo(w io.Writer) (n int64, err error) {
+	if lru.codec != nil {
+		return lru.writeCodec(w)
+	}
+	if lru.cache == nil {
+		return 0, nil
+	}
+
+	var m int
+	var buf bytes.Buffer
+	var size [4]byte
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		buf.Reset()
+		if err = gob.NewEncoder(&buf).Encode(&e.Value.(*lruEntry).Entry); err != nil {
+			return n, err
+		}
+		binary.BigEndian.PutUint32(size[:], uint32(buf.Len()))
+
+		m, err = w.Write(size[:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		m, err = w.Write(buf.Bytes())
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom reads entries written by WriteTo from r until EOF and adds them
+// behind the entries already in the cache, so the recency order of the
+// stream is preserved. Keys that are already cached have their value
+// replaced in place. Once the cache is full the remaining, least recently
+// used entries are read but dropped. A frame longer than MaxFrameSize
+// stops the read with ErrFrameTooLarge.
+func (lru *LRU) ReadFrom(r io.Reader) (n int64, err error) {
+	if lru.codec != nil {
+		return lru.readCodec(r)
+	}
+	lru.prepareLoad()
+
+	var m int
+	var buf []byte
+	var size [4]byte
+	for {
+		m, err = io.ReadFull(r, size[:])
+		n += int64(m)
+		if err == io.EOF {
+			lru.checkSize()
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		l32 := binary.BigEndian.Uint32(size[:])
+		if l32 > MaxFrameSize {
+			return n, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, l32)
+		}
+		l := int(l32)
+		if cap(buf) < l {
+			buf = make([]byte, l)
+		}
+		buf = buf[:l]
+		m, err = io.ReadFull(r, buf)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+
+		var kv cm.Entry
+		if err = gob.NewDecoder(bytes.NewReader(buf)).Decode(&kv); err != nil {
+			return n, err
+		}
+
+		lru.load(kv)
+	}
+}
+
+func (lru *LRU) prepareLoad() {
+	if lru.cache == nil {
+		lru.cache = make(map[cm.Key]*list.Element)
+		lru.ll = list.New()
+	}
+}
+
+// load adds a read entry behind the cached ones, or replaces the value of
+// a cached key in place; it drops the entry if the cache is full.
+func (lru *LRU) load(kv cm.Entry) {
+	if ee, ok := lru.cache[kv.K]; ok {
+		ee.Value.(*lruEntry).V = kv.V
+		return
+	}
+	if (lru.MaxEntries > 0 || lru.ZeroMeansEmpty) && (lru.ll.Len() >= lru.MaxEntries) {
+		return
+	}
+	lru.cache[kv.K] = lru.ll.PushBack(&lruEntry{Entry: kv, accessed: now()})
+}
@@ -0,0 +1,117 @@
+package lru_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"goalgutil/lru"
+)
+
+func TestLRUWriteToReadFrom(t *testing.T) {
+	src := lru.NewLRU(0)
+	src.Add("a", 1)
+	src.Add("b", "two")
+	src.Add(3, 3.0)
+	src.Get("a")
+
+	var buf bytes.Buffer
+	written, err := src.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("TestLRUWriteToReadFrom failed to write: %v", err)
+	}
+	if written != int64(buf.Len()) {
+		t.Fatalf("TestLRUWriteToReadFrom failed.  Wrote %d bytes, reported %d", buf.Len(), written)
+	}
+
+	dst := lru.NewLRU(0)
+	read, err := dst.ReadFrom(&buf)
+	if err != nil {
+		t.Fatalf("TestLRUWriteToReadFrom failed to read: %v", err)
+	}
+	if read != written {
+		t.Fatalf("TestLRUWriteToReadFrom failed.  Read %d bytes, wrote %d", read, written)
+	}
+	if n := dst.Len(); n != 3 {
+		t.Fatalf("TestLRUWriteToReadFrom failed.  Expected len %d, got %d", 3, n)
+	}
+
+	// the least recently used entry must be evicted first
+	dst.MaxEntries = 3
+	dst.Add("d", 4)
+	if _, ok := dst.Get("b"); ok {
+		t.Fatal("TestLRUWriteToReadFrom did not preserve recency order")
+	}
+
+	for k, want := range map[interface{}]interface{}{"a": 1, 3: 3.0, "d": 4} {
+		if val, ok := dst.Get(k); !ok || val != want {
+			t.Fatalf("TestLRUWriteToReadFrom failed.  Expected %v for %v, got %v", want, k, val)
+		}
+	}
+}
+
+func TestLRUReadFromFull(t *testing.T) {
+	src := lru.NewLRU(0)
+	src.Add("old", 1)
+	src.Add("new", 2)
+
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("TestLRUReadFromFull failed to write: %v", err)
+	}
+
+	dst := lru.NewLRU(1)
+	if _, err := dst.ReadFrom(&buf); err != nil {
+		t.Fatalf("TestLRUReadFromFull failed to read: %v", err)
+	}
+	if _, ok := dst.Get("new"); !ok {
+		t.Fatal("TestLRUReadFromFull dropped the most recently used entry")
+	}
+	if _, ok := dst.Get("old"); ok {
+		t.Fatal("TestLRUReadFromFull kept an entry over capacity")
+	}
+}
+
+func TestLRUReadFromZeroMeansEmpty(t *testing.T) {
+	src := lru.NewLRU(0)
+	src.Add("myKey", 1234)
+
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatalf("TestLRUReadFromZeroMeansEmpty failed to write: %v", err)
+	}
+
+	dst := lru.NewLRU(0)
+	dst.ZeroMeansEmpty = true
+	if _, err := dst.ReadFrom(&buf); err != nil {
+		t.Fatalf("TestLRUReadFromZeroMeansEmpty failed to read: %v", err)
+	}
+	if n := dst.Len(); n != 0 {
+		t.Fatalf("TestLRUReadFromZeroMeansEmpty failed.  Expected len %d, got %d", 0, n)
+	}
+}
+
+func TestLRUReadFromFrameTooLarge(t *testing.T) {
+	src := lru.NewLRU(0)
+	src.Add("a", 1)
+
+	var buf bytes.Buffer
+	written, err := src.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("TestLRUReadFromFrameTooLarge failed to write: %v", err)
+	}
+	// a forged length prefix of 4GiB-1 with no frame behind it
+	buf.Write([]byte{0xff, 0xff, 0xff, 0xff})
+
+	dst := lru.NewLRU(0)
+	read, err := dst.ReadFrom(&buf)
+	if !errors.Is(err, lru.ErrFrameTooLarge) {
+		t.Fatalf("TestLRUReadFromFrameTooLarge failed.  Expected %v, got %v", lru.ErrFrameTooLarge, err)
+	}
+	if read != written+4 {
+		t.Fatalf("TestLRUReadFromFrameTooLarge failed.  Expected %d bytes read, got %d", written+4, read)
+	}
+	if val, ok := dst.Get("a"); !ok || val != 1 {
+		t.Fatalf("TestLRUReadFromFrameTooLarge failed.  Expected %d, got %v", 1, val)
+	}
+}
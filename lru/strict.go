@@ -0,0 +1,22 @@
+package lru
+
+// StrictMode makes the caches panic on misuse instead of silently coping
+// with it. By default a cache that was Cleared, or built as a zero value
+// rather than by its constructor, quietly re-creates its internal state on
+// the next Add, and an invalid MaxEntries is tolerated. With StrictMode
+// set, Add and Get panic in both cases, which helps catch such bugs early.
+// It covers LRU, LRUK, LRU2Q, LRUMQ, GDSFCache and BytesLRU; COWCache has
+// no capacity and is meant to be used empty after Clear.
+var StrictMode bool
+
+func strictCheck(name string, initialized, validMaxEntries bool) {
+	if !StrictMode {
+		return
+	}
+	if !initialized {
+		panic(name + " used after Clear or without its constructor!")
+	}
+	if !validMaxEntries {
+		panic(name + " has an invalid MaxEntries!")
+	}
+}
@@ -0,0 +1,64 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+func TestStrictMode(t *testing.T) {
+	misuseTests := []struct {
+		name  string
+		cache func() cm.Cache
+	}{
+		{"lru_cleared", func() cm.Cache { c := lru.NewLRU(0); c.Clear(); return c }},
+		{"lru_zero_value", func() cm.Cache { return &lru.LRU{} }},
+		{"lru_negative_max", func() cm.Cache { c := lru.NewLRU(0); c.MaxEntries = -1; return c }},
+		{"lruk_cleared", func() cm.Cache { c := lru.NewLRUK(0, 1); c.Clear(); return c }},
+		{"lruk_negative_max", func() cm.Cache { c := lru.NewLRUK(0, 1); c.MaxEntries = -1; return c }},
+		{"lru2q_cleared", func() cm.Cache { c := lru.NewLRU2Q(1); c.Clear(); return c }},
+		{"lru2q_negative_max", func() cm.Cache { c := lru.NewLRU2Q(1); c.MaxEntries = -1; return c }},
+		{"gdsf_cleared", func() cm.Cache { c := lru.NewGDSFCache(1, nil); c.Clear(); return c }},
+		{"gdsf_negative_max", func() cm.Cache { c := lru.NewGDSFCache(1, nil); c.MaxEntries = -1; return c }},
+		{"lrumq_cleared", func() cm.Cache { c := lru.NewLRUMQ(1, 2, 1); c.Clear(); return c }},
+		{"lrumq_negative_max", func() cm.Cache { c := lru.NewLRUMQ(1, 2, 1); c.MaxEntries = -1; return c }},
+	}
+
+	defer func() { lru.StrictMode = false }()
+	for _, tt := range misuseTests {
+		lru.StrictMode = false
+		if panics(func() { c := tt.cache(); c.Get("myKey") }) {
+			t.Fatalf("%s: Get panicked in lenient mode", tt.name)
+		}
+
+		lru.StrictMode = true
+		if !panics(func() { c := tt.cache(); c.Add("myKey", 1234) }) {
+			t.Fatalf("%s: Add did not panic in strict mode", tt.name)
+		}
+		if !panics(func() { c := tt.cache(); c.Get("myKey") }) {
+			t.Fatalf("%s: Get did not panic in strict mode", tt.name)
+		}
+	}
+
+	lru.StrictMode = true
+	if panics(func() { c := lru.NewLRU(1); c.Add("myKey", 1234); c.Get("myKey") }) {
+		t.Fatal("TestStrictMode panicked on valid use")
+	}
+	if !panics(func() { c := lru.NewBytesLRU(1); c.Clear(); c.Add([]byte("myKey"), 1234) }) {
+		t.Fatal("TestStrictMode: BytesLRU Add did not panic in strict mode")
+	}
+	if !panics(func() { c := lru.NewBytesLRU(1); c.Clear(); c.Get([]byte("myKey")) }) {
+		t.Fatal("TestStrictMode: BytesLRU Get did not panic in strict mode")
+	}
+}
@@ -0,0 +1,79 @@
+package lru
+
+import (
+	"container/heap"
+
+	cm "goalgutil/macros/cache_macro"
+)
+
+// EntryInfo describes a cached entry along with the number of Gets that
+// found it since it was added.
+type EntryInfo struct {
+	cm.Entry
+
+	Hits int
+}
+
+// TopK returns the n most read entries, sorted by Hits from the highest.
+// Entries with the same number of hits are ordered from the most to the
+// least recently used. It does not change the recency of any entry.
+func (lru *LRU) TopK(n int) []EntryInfo {
+	if lru.cache == nil || n <= 0 {
+		return nil
+	}
+
+	// keep the n best entries seen so far in a min-heap, so that only
+	// the entries displacing its minimum cost O(log n)
+	var h topKHeap
+	pos := 0
+	for e := lru.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*lruEntry)
+		it := topKItem{EntryInfo{Entry: kv.Entry, Hits: kv.hits}, pos}
+		pos += 1
+
+		if len(h) < n {
+			heap.Push(&h, it)
+		} else if h.better(it, h[0]) {
+			h[0] = it
+			heap.Fix(&h, 0)
+		}
+	}
+
+	top := make([]EntryInfo, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(&h).(topKItem).EntryInfo
+	}
+	return top
+}
+
+type topKItem struct {
+	EntryInfo
+
+	pos int
+}
+
+type topKHeap []topKItem
+
+// better reports whether a ranks above b: more hits, or as many hits and
+// more recently used.
+func (h topKHeap) better(a, b topKItem) bool {
+	if a.Hits != b.Hits {
+		return a.Hits > b.Hits
+	}
+	return a.pos < b.pos
+}
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h.better(h[j], h[i]) }
+func (h topKHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *topKHeap) Push(x any) {
+	*h = append(*h, x.(topKItem))
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
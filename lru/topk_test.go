@@ -0,0 +1,43 @@
+package lru_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestLRUTopK(t *testing.T) {
+	c := lru.NewLRU(0)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		c.Add(k, k)
+	}
+	reads := map[string]int{"a": 3, "b": 1, "c": 5, "e": 1}
+	for _, k := range []string{"a", "b", "c", "e"} {
+		for i := 0; i < reads[k]; i++ {
+			c.Get(k)
+		}
+	}
+
+	topKTests := []struct {
+		n        int
+		expected []cm.Key
+		hits     []int
+	}{
+		{0, nil, nil},
+		{1, []cm.Key{"c"}, []int{5}},
+		{3, []cm.Key{"c", "a", "e"}, []int{5, 3, 1}},
+		{10, []cm.Key{"c", "a", "e", "b", "d"}, []int{5, 3, 1, 1, 0}},
+	}
+	for _, tt := range topKTests {
+		top := c.TopK(tt.n)
+		if len(top) != len(tt.expected) {
+			t.Fatalf("TopK(%d) returned %d entries; want %d", tt.n, len(top), len(tt.expected))
+		}
+		for i, info := range top {
+			if info.K != tt.expected[i] || info.Hits != tt.hits[i] || info.V != info.K {
+				t.Fatalf("TopK(%d)[%d] = %v; want %v with %d hits", tt.n, i, info, tt.expected[i], tt.hits[i])
+			}
+		}
+	}
+}
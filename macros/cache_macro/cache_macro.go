@@ -15,3 +15,21 @@ type Cache interface {
 	Len() int
 	Clear()
 }
+
+// Peeker is implemented by caches that can look up a value without
+// updating its recency.
+type Peeker interface {
+	Peek(k Key) (v Value, ok bool)
+}
+
+// Keyer is implemented by caches that can list their keys.
+type Keyer interface {
+	Keys() []Key
+}
+
+// KeyedCache is a Cache that can list its keys, as required by Equal and
+// Diff. Every Cache in package lru implements it, and Peeker too.
+type KeyedCache interface {
+	Cache
+	Keyer
+}
@@ -0,0 +1,55 @@
+package cache_macro
+
+import "reflect"
+
+// Equal reports whether a and b hold the same keys mapped to deeply equal
+// values, ignoring recency order. Values are read with Peek where available
+// so that recency is left untouched.
+func Equal(a, b KeyedCache) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+
+	for _, k := range a.Keys() {
+		va, _ := lookup(a, k)
+		vb, ok := lookup(b, k)
+		if !ok || !reflect.DeepEqual(va, vb) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the keys held only by a and the keys held only by b.
+// Keys held by both are not reported, even if their values differ.
+func Diff(a, b KeyedCache) (onlyA, onlyB []Key) {
+	ka, kb := a.Keys(), b.Keys()
+
+	inA := make(map[Key]struct{}, len(ka))
+	for _, k := range ka {
+		inA[k] = struct{}{}
+	}
+	inB := make(map[Key]struct{}, len(kb))
+	for _, k := range kb {
+		inB[k] = struct{}{}
+	}
+
+	for _, k := range ka {
+		if _, ok := inB[k]; !ok {
+			onlyA = append(onlyA, k)
+		}
+	}
+	for _, k := range kb {
+		if _, ok := inA[k]; !ok {
+			onlyB = append(onlyB, k)
+		}
+	}
+	return onlyA, onlyB
+}
+
+func lookup(c Cache, k Key) (Value, bool) {
+	if p, ok := c.(Peeker); ok {
+		return p.Peek(k)
+	}
+	return c.Get(k)
+}
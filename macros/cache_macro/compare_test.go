@@ -0,0 +1,82 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+// Every cache in package lru can be compared with Equal and Diff.
+var (
+	_ cm.KeyedCache = (*lru.LRU)(nil)
+	_ cm.KeyedCache = (*lru.LRUK)(nil)
+	_ cm.KeyedCache = (*lru.LRU2Q)(nil)
+	_ cm.KeyedCache = (*lru.LRUMQ)(nil)
+	_ cm.KeyedCache = (*lru.GDSFCache)(nil)
+	_ cm.KeyedCache = (*lru.COWCache)(nil)
+)
+
+func TestEqualDiff(t *testing.T) {
+	type entries map[interface{}]interface{}
+
+	compareTests := []struct {
+		name          string
+		a, b          entries
+		expectedEqual bool
+		expectedOnlyA int
+		expectedOnlyB int
+	}{
+		{"equal", entries{"a": 1, "b": []int{2}}, entries{"b": []int{2}, "a": 1}, true, 0, 0},
+		{"extra_in_a", entries{"a": 1, "b": 2}, entries{"a": 1}, false, 1, 0},
+		{"extra_in_b", entries{"a": 1}, entries{"a": 1, "c": 3}, false, 0, 1},
+		{"differing_value", entries{"a": 1, "b": 2}, entries{"a": 1, "b": 3}, false, 0, 0},
+		{"disjoint", entries{"a": 1}, entries{"b": 1}, false, 1, 1},
+	}
+	for _, tt := range compareTests {
+		a, b := lru.NewLRU(0), lru.NewLRU(0)
+		for k, v := range tt.a {
+			a.Add(k, v)
+		}
+		for k, v := range tt.b {
+			b.Add(k, v)
+		}
+
+		if eq := cm.Equal(a, b); eq != tt.expectedEqual {
+			t.Fatalf("%s: Equal = %v; want %v", tt.name, eq, tt.expectedEqual)
+		}
+		onlyA, onlyB := cm.Diff(a, b)
+		if len(onlyA) != tt.expectedOnlyA || len(onlyB) != tt.expectedOnlyB {
+			t.Fatalf("%s: Diff = (%v, %v); want %d and %d keys", tt.name, onlyA, onlyB, tt.expectedOnlyA, tt.expectedOnlyB)
+		}
+	}
+}
+
+func TestEqualAcrossCaches(t *testing.T) {
+	replicas := []struct {
+		name  string
+		cache func() cm.KeyedCache
+	}{
+		{"lruk", func() cm.KeyedCache { return lru.NewLRUK(0, 1) }},
+		{"lru2q", func() cm.KeyedCache { return lru.NewLRU2Q(0) }},
+		{"lrumq", func() cm.KeyedCache { return lru.NewLRUMQ(0, 2, 0) }},
+		{"gdsf", func() cm.KeyedCache { return lru.NewGDSFCache(0, nil) }},
+		{"cow", func() cm.KeyedCache { return lru.NewCOWCache() }},
+	}
+	for _, tt := range replicas {
+		primary, replica := lru.NewLRU(0), tt.cache()
+		for i := 0; i < 5; i++ {
+			primary.Add(i, i)
+			replica.Add(i, i)
+		}
+		replica.Get(0)
+		if !cm.Equal(primary, replica) || !cm.Equal(replica, primary) {
+			t.Fatalf("%s: Equal = false for equal contents", tt.name)
+		}
+
+		replica.Remove(3)
+		if onlyA, onlyB := cm.Diff(primary, replica); len(onlyA) != 1 || onlyA[0] != 3 || len(onlyB) != 0 {
+			t.Fatalf("%s: Diff = (%v, %v); want ([3], [])", tt.name, onlyA, onlyB)
+		}
+	}
+}
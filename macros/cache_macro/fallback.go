@@ -0,0 +1,108 @@
+package cache_macro
+
+// FallbackCache looks a key up in an ordered list of caches, from the
+// fastest to the slowest, and finally in a loader. A hit in a later tier,
+// or a value produced by the loader, is written back to every earlier
+// tier so that the next lookup is served sooner.
+type FallbackCache struct {
+	Tiers []Cache
+
+	// Loader optionally produces values missing from every tier.
+	Loader func(k Key) (v Value, ok bool)
+
+	// Fresher optionally reports whether value a is newer than value b,
+	// for example by comparing versions they carry. When set, Get looks
+	// a key up in every tier instead of stopping at the first hit, and
+	// the freshest value found is returned and written to every tier
+	// before the one holding it, repairing stale copies.
+	Fresher func(a, b Value) bool
+}
+
+// NewFallbackCache creates a FallbackCache over tiers, consulted in order,
+// backed by loader, which may be nil.
+func NewFallbackCache(loader func(k Key) (v Value, ok bool), tiers ...Cache) *FallbackCache {
+	return &FallbackCache{Tiers: tiers, Loader: loader}
+}
+
+// Add adds a value to every tier.
+func (fc *FallbackCache) Add(k Key, v Value) {
+	for _, c := range fc.Tiers {
+		c.Add(k, v)
+	}
+}
+
+// Get looks up a key's value from the tiers in order, then the loader.
+func (fc *FallbackCache) Get(k Key) (v Value, ok bool) {
+	if fc.Fresher != nil {
+		return fc.getFreshest(k)
+	}
+
+	for i, c := range fc.Tiers {
+		if v, ok = c.Get(k); ok {
+			fc.populate(i, k, v)
+			return v, true
+		}
+	}
+
+	if fc.Loader == nil {
+		return nil, false
+	}
+	if v, ok = fc.Loader(k); !ok {
+		return nil, false
+	}
+	fc.populate(len(fc.Tiers), k, v)
+	return v, true
+}
+
+// getFreshest looks up a key's value from every tier and returns the
+// freshest, then the loader.
+func (fc *FallbackCache) getFreshest(k Key) (v Value, ok bool) {
+	n := -1
+	for i, c := range fc.Tiers {
+		if cv, hit := c.Get(k); hit && (n < 0 || fc.Fresher(cv, v)) {
+			n, v = i, cv
+		}
+	}
+
+	if n >= 0 {
+		fc.populate(n, k, v)
+		return v, true
+	}
+	if fc.Loader == nil {
+		return nil, false
+	}
+	if v, ok = fc.Loader(k); !ok {
+		return nil, false
+	}
+	fc.populate(len(fc.Tiers), k, v)
+	return v, true
+}
+
+// Remove removes the provided key from every tier.
+func (fc *FallbackCache) Remove(k Key) {
+	for _, c := range fc.Tiers {
+		c.Remove(k)
+	}
+}
+
+// Len returns the number of items in the first tier.
+func (fc *FallbackCache) Len() int {
+	if len(fc.Tiers) == 0 {
+		return 0
+	}
+	return fc.Tiers[0].Len()
+}
+
+// Clear purges all entries from every tier.
+func (fc *FallbackCache) Clear() {
+	for _, c := range fc.Tiers {
+		c.Clear()
+	}
+}
+
+// populate writes a value into the tiers before tier n.
+func (fc *FallbackCache) populate(n int, k Key, v Value) {
+	for _, c := range fc.Tiers[:n] {
+		c.Add(k, v)
+	}
+}
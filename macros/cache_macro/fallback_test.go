@@ -0,0 +1,92 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestFallbackCache(t *testing.T) {
+	var lookups []string
+	l1, l2 := lru.NewLRU(0), lru.NewLRU(0)
+	loader := func(k cm.Key) (cm.Value, bool) {
+		lookups = append(lookups, "loader")
+		if k == "missing" {
+			return nil, false
+		}
+		return "loaded", true
+	}
+	fc := cm.NewFallbackCache(loader, l1, l2)
+
+	l2.Add("remote", "l2")
+	if val, ok := fc.Get("remote"); !ok || val != "l2" {
+		t.Fatalf("TestFallbackCache failed.  Expected %q, got %v", "l2", val)
+	}
+	if val, ok := l1.Get("remote"); !ok || val != "l2" {
+		t.Fatal("TestFallbackCache did not populate the first tier from the second")
+	}
+	if len(lookups) != 0 {
+		t.Fatal("TestFallbackCache called the loader on a tier hit")
+	}
+
+	if val, ok := fc.Get("fresh"); !ok || val != "loaded" {
+		t.Fatalf("TestFallbackCache failed.  Expected %q, got %v", "loaded", val)
+	}
+	if _, ok := l1.Get("fresh"); !ok {
+		t.Fatal("TestFallbackCache did not populate the first tier from the loader")
+	}
+	if _, ok := l2.Get("fresh"); !ok {
+		t.Fatal("TestFallbackCache did not populate the second tier from the loader")
+	}
+
+	l1.Add("local", "l1")
+	l2.Add("local", "stale")
+	if val, _ := fc.Get("local"); val != "l1" {
+		t.Fatalf("TestFallbackCache consulted tiers out of order, got %v", val)
+	}
+
+	if _, ok := fc.Get("missing"); ok {
+		t.Fatal("TestFallbackCache returned a value nobody had")
+	}
+	if len(lookups) != 2 {
+		t.Fatalf("TestFallbackCache failed.  Expected %d loader calls, got %d", 2, len(lookups))
+	}
+
+	fc.Remove("local")
+	if _, ok := l2.Get("local"); ok {
+		t.Fatal("TestFallbackCache did not remove from every tier")
+	}
+}
+
+func TestFallbackCacheFresher(t *testing.T) {
+	type versioned struct {
+		version int
+		data    string
+	}
+
+	l1, l2, l3 := lru.NewLRU(0), lru.NewLRU(0), lru.NewLRU(0)
+	fc := cm.NewFallbackCache(nil, l1, l2, l3)
+	fc.Fresher = func(a, b cm.Value) bool {
+		return a.(versioned).version > b.(versioned).version
+	}
+
+	l1.Add("k", versioned{1, "stale"})
+	l2.Add("k", versioned{3, "fresh"})
+	l3.Add("k", versioned{2, "older"})
+
+	want := versioned{3, "fresh"}
+	if val, ok := fc.Get("k"); !ok || val != want {
+		t.Fatalf("TestFallbackCacheFresher failed.  Expected %v, got %v", want, val)
+	}
+	if val, _ := l1.Get("k"); val != want {
+		t.Fatalf("TestFallbackCacheFresher did not repair the first tier, got %v", val)
+	}
+	if val, _ := l3.Get("k"); val != (versioned{2, "older"}) {
+		t.Fatalf("TestFallbackCacheFresher wrote below the freshest tier, got %v", val)
+	}
+
+	if _, ok := fc.Get("missing"); ok {
+		t.Fatal("TestFallbackCacheFresher returned a value nobody had")
+	}
+}
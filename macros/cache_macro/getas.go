@@ -0,0 +1,17 @@
+package cache_macro
+
+// GetAs looks up a key's value from c and asserts it to T. It returns the
+// zero value of T and false on a miss or when the value is not a T.
+func GetAs[T any](c Cache, k Key) (T, bool) {
+	var zero T
+
+	v, ok := c.Get(k)
+	if !ok {
+		return zero, false
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, false
+	}
+	return t, true
+}
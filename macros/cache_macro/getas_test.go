@@ -0,0 +1,24 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestGetAs(t *testing.T) {
+	c := lru.NewLRU(0)
+	c.Add("int", 1234)
+	c.Add("string", "value")
+
+	if val, ok := cm.GetAs[int](c, "int"); !ok || val != 1234 {
+		t.Fatalf("TestGetAs failed.  Expected %d, got %v", 1234, val)
+	}
+	if val, ok := cm.GetAs[int](c, "string"); ok || val != 0 {
+		t.Fatalf("TestGetAs failed on a mismatched type.  Expected 0, false, got %v, %v", val, ok)
+	}
+	if val, ok := cm.GetAs[string](c, "missing"); ok || val != "" {
+		t.Fatalf("TestGetAs failed on a missing key.  Expected \"\", false, got %q, %v", val, ok)
+	}
+}
@@ -0,0 +1,16 @@
+package cache_macro
+
+// Move transfers the entry for k from src to dst, returning false if src
+// does not hold k. If src is a Peeker the entry is read without being
+// promoted first. Move is not synchronized; callers sharing the caches
+// between goroutines must hold their locks around it.
+func Move(src, dst Cache, k Key) bool {
+	v, ok := lookup(src, k)
+	if !ok {
+		return false
+	}
+
+	src.Remove(k)
+	dst.Add(k, v)
+	return true
+}
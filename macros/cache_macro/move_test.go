@@ -0,0 +1,30 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestMove(t *testing.T) {
+	src, dst := lru.NewLRU(0), lru.NewLRU(0)
+	src.Add("myKey", 1234)
+
+	if !cm.Move(src, dst, "myKey") {
+		t.Fatal("TestMove failed to move a cached entry")
+	}
+	if _, ok := src.Get("myKey"); ok {
+		t.Fatal("TestMove left the entry in the source cache")
+	}
+	if val, ok := dst.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestMove failed.  Expected %d, got %v", 1234, val)
+	}
+
+	if cm.Move(src, dst, "nonsense") {
+		t.Fatal("TestMove moved a missing entry")
+	}
+	if n := dst.Len(); n != 1 {
+		t.Fatalf("TestMove failed.  Expected len %d, got %d", 1, n)
+	}
+}
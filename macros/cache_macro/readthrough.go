@@ -0,0 +1,27 @@
+package cache_macro
+
+type readThrough struct {
+	Cache
+
+	loader func(k Key) (v Value, ok bool)
+}
+
+// ReadThrough wraps c so that a Get which misses calls loader and, if the
+// loader reports success, stores the loaded value in c before returning it.
+// Add, Remove, Len and Clear pass straight through to c.
+func ReadThrough(c Cache, loader func(k Key) (v Value, ok bool)) Cache {
+	return &readThrough{Cache: c, loader: loader}
+}
+
+// Get looks up a key's value from the cache, loading it on a miss.
+func (rt *readThrough) Get(k Key) (v Value, ok bool) {
+	if v, ok = rt.Cache.Get(k); ok {
+		return v, true
+	}
+
+	if v, ok = rt.loader(k); !ok {
+		return nil, false
+	}
+	rt.Cache.Add(k, v)
+	return v, true
+}
@@ -0,0 +1,47 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestReadThrough(t *testing.T) {
+	loads := 0
+	loader := func(k cm.Key) (cm.Value, bool) {
+		if k == "missing" {
+			return nil, false
+		}
+		loads++
+		return 1234, true
+	}
+
+	c := lru.NewLRU(0)
+	rt := cm.ReadThrough(c, loader)
+
+	if val, ok := rt.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestReadThrough failed.  Expected %d, got %v", 1234, val)
+	}
+	if val, ok := c.Get("myKey"); !ok || val != 1234 {
+		t.Fatal("TestReadThrough did not populate the underlying cache")
+	}
+	if val, ok := rt.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestReadThrough failed.  Expected %d, got %v", 1234, val)
+	}
+	if loads != 1 {
+		t.Fatalf("TestReadThrough failed.  Expected %d load, got %d", 1, loads)
+	}
+
+	if _, ok := rt.Get("missing"); ok {
+		t.Fatal("TestReadThrough returned a value the loader did not provide")
+	}
+	if n := rt.Len(); n != 1 {
+		t.Fatalf("TestReadThrough failed.  Expected len %d, got %d", 1, n)
+	}
+
+	rt.Remove("myKey")
+	if _, ok := c.Get("myKey"); ok {
+		t.Fatal("TestReadThrough did not pass Remove through")
+	}
+}
@@ -0,0 +1,80 @@
+package cache_macro
+
+// Op is a single recorded cache operation. Op is "add", "get", "remove" or
+// "clear"; Key is unset for "clear" and Value is only set for "add".
+type Op struct {
+	Op    string
+	Key   Key
+	Value Value
+}
+
+// Recorder wraps a Cache and appends every Add, Get, Remove and Clear to
+// Ops before passing it on, so that a trace captured from a live cache can
+// be replayed later with Replay. Len is not recorded.
+type Recorder struct {
+	Cache
+
+	Ops []Op
+}
+
+// NewRecorder creates a Recorder over c.
+func NewRecorder(c Cache) *Recorder {
+	return &Recorder{Cache: c}
+}
+
+// Add adds a value to the cache.
+func (r *Recorder) Add(k Key, v Value) {
+	r.Ops = append(r.Ops, Op{Op: "add", Key: k, Value: v})
+	r.Cache.Add(k, v)
+}
+
+// Get looks up a key's value from the cache.
+func (r *Recorder) Get(k Key) (v Value, ok bool) {
+	r.Ops = append(r.Ops, Op{Op: "get", Key: k})
+	return r.Cache.Get(k)
+}
+
+// Remove removes the provided key from the cache.
+func (r *Recorder) Remove(k Key) {
+	r.Ops = append(r.Ops, Op{Op: "remove", Key: k})
+	r.Cache.Remove(k)
+}
+
+// Clear purges all entries from the cache.
+func (r *Recorder) Clear() {
+	r.Ops = append(r.Ops, Op{Op: "clear"})
+	r.Cache.Clear()
+}
+
+// Replay applies ops to c in order and summarizes the run. Hits and
+// Misses count the "get" operations, and HitRate is Hits divided by their
+// number. Evictions are counted around each "add" just as in Simulate.
+// Replay panics on an unknown operation.
+func Replay(ops []Op, c Cache) SimResult {
+	var res SimResult
+
+	for _, op := range ops {
+		switch op.Op {
+		case "add":
+			res.Evictions += addEvicting(c, op.Key, op.Value)
+		case "get":
+			if _, ok := c.Get(op.Key); ok {
+				res.Hits += 1
+			} else {
+				res.Misses += 1
+			}
+		case "remove":
+			c.Remove(op.Key)
+		case "clear":
+			c.Clear()
+		default:
+			panic("unknown operation " + op.Op + "!")
+		}
+	}
+
+	res.FinalSize = c.Len()
+	if n := res.Hits + res.Misses; n > 0 {
+		res.HitRate = float64(res.Hits) / float64(n)
+	}
+	return res
+}
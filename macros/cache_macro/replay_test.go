@@ -0,0 +1,62 @@
+package cache_macro_test
+
+import (
+	"reflect"
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestRecorderReplay(t *testing.T) {
+	live := lru.NewLRU(3)
+	rec := cm.NewRecorder(live)
+
+	// a hit, d evicts b, b miss, a updated, d removed, f evicts c, a hit
+	for _, k := range []string{"a", "b", "c"} {
+		rec.Add(k, k)
+	}
+	rec.Get("a")
+	rec.Add("d", "d")
+	rec.Get("b")
+	rec.Add("a", "A")
+	rec.Remove("d")
+	rec.Add("e", "e")
+	rec.Add("f", "f")
+	rec.Get("a")
+
+	if len(rec.Ops) != 11 || rec.Ops[7] != (cm.Op{Op: "remove", Key: "d"}) {
+		t.Fatalf("TestRecorderReplay recorded %v", rec.Ops)
+	}
+
+	fresh := lru.NewLRU(3)
+	res := cm.Replay(rec.Ops, fresh)
+	expected := cm.SimResult{Hits: 2, Misses: 1, Evictions: 2, FinalSize: 3, HitRate: 2.0 / 3}
+	if res != expected {
+		t.Fatalf("TestRecorderReplay failed.  Expected %+v, got %+v", expected, res)
+	}
+	if !reflect.DeepEqual(fresh.Snapshot(), live.Snapshot()) {
+		t.Fatalf("TestRecorderReplay failed.  Expected %v, got %v", live.Snapshot(), fresh.Snapshot())
+	}
+
+	rec.Clear()
+	if res := cm.Replay(rec.Ops, lru.NewLRU(3)); res.FinalSize != 0 {
+		t.Fatalf("TestRecorderReplay failed.  Expected an empty cache after clear, got %+v", res)
+	}
+}
+
+func TestReplayStagedAdds(t *testing.T) {
+	// an LRUK only stages a key on its first Add, so none of these evict
+	ops := []cm.Op{
+		{Op: "add", Key: "a", Value: "a"},
+		{Op: "add", Key: "b", Value: "b"},
+		{Op: "add", Key: "a", Value: "A"},
+		{Op: "add", Key: "c", Value: "c"},
+	}
+
+	res := cm.Replay(ops, lru.NewLRUK(1, 2))
+	expected := cm.SimResult{Evictions: 0, FinalSize: 1}
+	if res != expected {
+		t.Fatalf("TestReplayStagedAdds failed.  Expected %+v, got %+v", expected, res)
+	}
+}
@@ -0,0 +1,70 @@
+package cache_macro
+
+// SimResult summarizes the replay of an access trace through a cache.
+type SimResult struct {
+	Hits      int
+	Misses    int
+	Evictions int
+	FinalSize int
+
+	// HitRate is Hits divided by the length of the trace.
+	HitRate float64
+}
+
+// Simulate replays trace through a fresh cache built by factory. Each key
+// is looked up with Get and, on a miss, added with itself as the value.
+// Evictions counts the resident keys pushed out by those Adds; for a
+// Cache that is not a Keyer only a shrinking Len can be seen.
+func Simulate(trace []Key, factory func() Cache) SimResult {
+	var res SimResult
+
+	c := factory()
+	for _, k := range trace {
+		if _, ok := c.Get(k); ok {
+			res.Hits += 1
+			continue
+		}
+		res.Misses += 1
+
+		res.Evictions += addEvicting(c, k, k)
+	}
+
+	res.FinalSize = c.Len()
+	if len(trace) > 0 {
+		res.HitRate = float64(res.Hits) / float64(len(trace))
+	}
+	return res
+}
+
+// addEvicting adds a value to c and returns how many resident entries the
+// Add evicted. For a Keyer it counts the keys present before the Add and
+// missing after it, which is exact whatever the cache does with the new
+// key: an LRUK that only stages it, or a cache that drops it at once,
+// evicts nothing. Other caches only report the entries by which Len
+// shrank, which misses evictions that made room for the new key.
+func addEvicting(c Cache, k Key, v Value) int {
+	kr, ok := c.(Keyer)
+	if !ok {
+		n := c.Len()
+		c.Add(k, v)
+		if d := n - c.Len(); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	before := kr.Keys()
+	c.Add(k, v)
+
+	after := make(map[Key]struct{}, len(before)+1)
+	for _, k := range kr.Keys() {
+		after[k] = struct{}{}
+	}
+	evicted := 0
+	for _, k := range before {
+		if _, ok := after[k]; !ok {
+			evicted += 1
+		}
+	}
+	return evicted
+}
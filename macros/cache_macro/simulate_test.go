@@ -0,0 +1,65 @@
+package cache_macro_test
+
+import (
+	"testing"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+func TestSimulate(t *testing.T) {
+	// With room for two entries:
+	// a miss, b miss, a hit, c miss (evicts b), b miss (evicts a), a miss (evicts c), c miss (evicts b), a hit
+	trace := []cm.Key{"a", "b", "a", "c", "b", "a", "c", "a"}
+
+	simTests := []struct {
+		name       string
+		maxEntries int
+		expected   cm.SimResult
+	}{
+		{"capacity_2", 2, cm.SimResult{Hits: 2, Misses: 6, Evictions: 4, FinalSize: 2, HitRate: 0.25}},
+		{"capacity_3", 3, cm.SimResult{Hits: 5, Misses: 3, Evictions: 0, FinalSize: 3, HitRate: 0.625}},
+	}
+	for _, tt := range simTests {
+		res := cm.Simulate(trace, func() cm.Cache { return lru.NewLRU(tt.maxEntries) })
+		if res != tt.expected {
+			t.Fatalf("%s: got %+v; want %+v", tt.name, res, tt.expected)
+		}
+	}
+
+	if res := cm.Simulate(nil, func() cm.Cache { return lru.NewLRU(1) }); res != (cm.SimResult{}) {
+		t.Fatalf("empty trace: got %+v; want zero result", res)
+	}
+}
+
+func TestSimulateLRUK(t *testing.T) {
+	trace := []cm.Key{"a", "a", "b", "b", "a", "b"}
+
+	tests := []struct {
+		k        int
+		expected cm.SimResult
+	}{
+		// Get counts towards K, so each missed key is promoted by the
+		// Add that follows and pushes out the other one
+		{2, cm.SimResult{Hits: 2, Misses: 4, Evictions: 3, FinalSize: 1, HitRate: 2.0 / 6}},
+		// the Add after a miss only stages the key, and the next Get
+		// promotes it, so no Add evicts anything
+		{3, cm.SimResult{Hits: 3, Misses: 3, Evictions: 0, FinalSize: 1, HitRate: 3.0 / 6}},
+	}
+
+	for _, tt := range tests {
+		res := cm.Simulate(trace, func() cm.Cache { return lru.NewLRUK(1, tt.k) })
+		if res != tt.expected {
+			t.Fatalf("TestSimulateLRUK failed.  K %d: expected %+v, got %+v", tt.k, tt.expected, res)
+		}
+	}
+
+	res := cm.Simulate(trace, func() cm.Cache {
+		c := lru.NewLRU(0)
+		c.ZeroMeansEmpty = true
+		return c
+	})
+	if res.Evictions != 0 || res.FinalSize != 0 {
+		t.Fatalf("TestSimulateLRUK failed.  Expected no evictions from an empty cache, got %d", res.Evictions)
+	}
+}
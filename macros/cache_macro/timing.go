@@ -0,0 +1,45 @@
+package cache_macro
+
+import "time"
+
+// TimingCache wraps a Cache and reports every Add, Get and Remove that
+// takes longer than Threshold to OnSlow. Len and Clear pass straight
+// through to the wrapped cache.
+type TimingCache struct {
+	Cache
+
+	Threshold time.Duration
+
+	// OnSlow is called with the name of the slow operation, "add", "get"
+	// or "remove", and how long it took.
+	OnSlow func(op string, d time.Duration)
+}
+
+// NewTimingCache creates a TimingCache over c.
+func NewTimingCache(c Cache, threshold time.Duration, onSlow func(op string, d time.Duration)) *TimingCache {
+	return &TimingCache{Cache: c, Threshold: threshold, OnSlow: onSlow}
+}
+
+// Add adds a value to the cache.
+func (tc *TimingCache) Add(k Key, v Value) {
+	defer tc.time("add", time.Now())
+	tc.Cache.Add(k, v)
+}
+
+// Get looks up a key's value from the cache.
+func (tc *TimingCache) Get(k Key) (v Value, ok bool) {
+	defer tc.time("get", time.Now())
+	return tc.Cache.Get(k)
+}
+
+// Remove removes the provided key from the cache.
+func (tc *TimingCache) Remove(k Key) {
+	defer tc.time("remove", time.Now())
+	tc.Cache.Remove(k)
+}
+
+func (tc *TimingCache) time(op string, start time.Time) {
+	if d := time.Since(start); d > tc.Threshold && tc.OnSlow != nil {
+		tc.OnSlow(op, d)
+	}
+}
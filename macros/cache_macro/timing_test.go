@@ -0,0 +1,38 @@
+package cache_macro_test
+
+import (
+	"testing"
+	"time"
+
+	"goalgutil/lru"
+	cm "goalgutil/macros/cache_macro"
+)
+
+// slowCache delays every Get.
+type slowCache struct {
+	cm.Cache
+	delay time.Duration
+}
+
+func (sc *slowCache) Get(k cm.Key) (cm.Value, bool) {
+	time.Sleep(sc.delay)
+	return sc.Cache.Get(k)
+}
+
+func TestTimingCache(t *testing.T) {
+	var slow []string
+	onSlow := func(op string, d time.Duration) {
+		slow = append(slow, op)
+	}
+
+	tc := cm.NewTimingCache(&slowCache{Cache: lru.NewLRU(0), delay: 20 * time.Millisecond}, 5*time.Millisecond, onSlow)
+	tc.Add("myKey", 1234)
+	if val, ok := tc.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("TestTimingCache failed.  Expected %d, got %v", 1234, val)
+	}
+	tc.Remove("myKey")
+
+	if len(slow) != 1 || slow[0] != "get" {
+		t.Fatalf("TestTimingCache failed.  Expected slow ops [get], got %v", slow)
+	}
+}